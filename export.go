@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/jotup/internal/extern/js/katex"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/hl"
+	"github.com/diamondburned/jotup/internal/jotup/export"
+)
+
+const exportUsage = `Usage: jotup export [flags] <file.md | folder>
+
+Converts a Markdown file or a whole folder of Markdown files into standalone
+HTML files without starting the GUI.
+
+Flags:
+`
+
+// runExport runs the "jotup export" subcommand. It never initializes GTK.
+func runExport(args []string) int {
+	config := configDir()
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), exportUsage)
+		flags.PrintDefaults()
+	}
+
+	output := flags.String("o", "", "output file, or output folder if exporting a folder")
+	style := flags.String("style", savedStyle(config), "chroma style for code blocks")
+	noMath := flags.Bool("no-math", false, "do not load KaTeX to render math into MathML")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	opts := export.Options{
+		Style: *style,
+		Progress: func(src, dst string) {
+			log.Println("export:", src, "->", dst)
+		},
+	}
+	if config != "" {
+		opts.StyleDir = filepath.Join(config, "styles")
+	}
+
+	if err := exportPath(flags.Arg(0), *output, opts, !*noMath); err != nil {
+		log.Println("export:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func exportPath(src, dst string, opts export.Options, math bool) error {
+	s, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if math {
+		// Math is still exported as TeX if KaTeX can't be loaded, such as
+		// without a network connection.
+		m, err := katex.NewModule(context.Background())
+		if err != nil {
+			log.Println("export: cannot load KaTeX, math is kept as TeX:", err)
+		} else {
+			opts.Math = m
+		}
+	}
+
+	exporter, err := export.NewExporter(opts)
+	if err != nil {
+		return err
+	}
+
+	if s.IsDir() {
+		if dst == "" {
+			return fmt.Errorf("exporting a folder requires an output folder (-o)")
+		}
+		return exporter.ConvertDir(src, dst)
+	}
+
+	if dst == "" {
+		dst = export.HTMLPath(src)
+	}
+	return exporter.ConvertFile(src, dst)
+}
+
+// configDir returns the application's config directory, which is the same as
+// app.ConfigPath, without creating the application.
+func configDir() string {
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(d, appID[strings.LastIndexByte(appID, '.')+1:])
+}
+
+// savedStyle returns the code highlight style saved in the user's preferences.
+// The preferences are read directly instead of through prefs.LoadData, since
+// some properties need GTK to be loaded.
+func savedStyle(config string) string {
+	if config == "" {
+		return ""
+	}
+
+	data, err := os.ReadFile(filepath.Join(config, "prefs.json"))
+	if err != nil {
+		return ""
+	}
+
+	var props map[string]json.RawMessage
+	if err := json.Unmarshal(data, &props); err != nil {
+		return ""
+	}
+
+	var style string
+	json.Unmarshal(props[string(hl.Style.Meta().ID())], &style)
+	return style
+}
@@ -0,0 +1,43 @@
+// Package gtksourcex has the parts of GtkSourceView that the gotk4-sourceview
+// bindings don't have yet.
+package gtksourcex
+
+// #cgo pkg-config: gobject-2.0
+// #cgo linux LDFLAGS: -ldl
+// #define _GNU_SOURCE
+// #include <dlfcn.h>
+// #include <glib-object.h>
+//
+// // The type is looked up at runtime instead of being linked, so that
+// // GtkSourceView older than 5.4 can still run without it.
+// static GType vim_im_context_type(void) {
+// 	GType (*get_type)(void) = dlsym(RTLD_DEFAULT, "gtk_source_vim_im_context_get_type");
+// 	return get_type ? get_type() : G_TYPE_INVALID;
+// }
+//
+// static gpointer new_object(GType type) {
+// 	return g_object_new_with_properties(type, 0, NULL, NULL);
+// }
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// NewVimIMContext creates a GtkSourceVimIMContext, which emulates Vim in the
+// view that it's the input method of. Nil is returned if GtkSourceView is older
+// than 5.4, which added it.
+func NewVimIMContext() *gtk.IMContext {
+	t := C.vim_im_context_type()
+	if t == 0 {
+		return nil
+	}
+
+	return &gtk.IMContext{
+		Object: coreglib.AssumeOwnership(unsafe.Pointer(C.new_object(t))),
+	}
+}
@@ -13,7 +13,6 @@ import (
 	"strings"
 	"unicode"
 
-	"github.com/diamondburned/gotkit/app"
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/require"
 	"github.com/go-git/go-git/v5"
@@ -164,14 +163,15 @@ func gitClone(ctx context.Context, url, dst string) error {
 	return nil
 }
 
-// assetDir gets the assets directory. It panics if the directory cannot be
-// obtained.
+// assetDir gets the assets directory within the application's cache
+// directory. It's found without the application, so that headless commands
+// don't need GTK.
 func assetDir(ctx context.Context) string {
-	app := app.FromContext(ctx)
-	if app != nil {
-		return app.CachePath("assets", "js")
+	d, err := os.UserCacheDir()
+	if err != nil {
+		d = os.TempDir()
 	}
-	return filepath.Join(os.TempDir(), "jotup", "assets", "js")
+	return filepath.Join(d, "jotup", "assets", "js")
 }
 
 func trimExt(name string) string {
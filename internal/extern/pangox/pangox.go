@@ -0,0 +1,22 @@
+// Package pangox has the parts of Pango that the gotk4 bindings don't have
+// yet.
+package pangox
+
+// #cgo pkg-config: pango
+// #include <pango/pango.h>
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/diamondburned/gotk4/pkg/core/gextras"
+	"github.com/diamondburned/gotk4/pkg/pango"
+)
+
+// SetAttrRange sets the byte range that the attribute applies to. The bindings
+// have no setters for the start_index and end_index fields.
+func SetAttrRange(attr *pango.Attribute, start, end int) {
+	native := (*C.PangoAttribute)(gextras.StructNative(unsafe.Pointer(attr)))
+	native.start_index = C.guint(start)
+	native.end_index = C.guint(end)
+}
@@ -13,8 +13,18 @@ import (
 	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/jotup/internal/jotup/editor"
 	"github.com/diamondburned/jotup/internal/jotup/filetree"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+	"github.com/diamondburned/jotup/internal/jotup/vcspanel"
 )
 
+func init() {
+	keymap.Register(
+		keymap.Binding{Action: "pane.split-right", Label: "Split Pane Right"},
+		keymap.Binding{Action: "pane.split-down", Label: "Split Pane Down"},
+		keymap.Binding{Action: "pane.close", Label: "Close Pane"},
+	)
+}
+
 // EditorPage is the page containing the file tree and the text editor.
 type EditorPage struct {
 	*adaptive.Fold
@@ -22,17 +32,27 @@ type EditorPage struct {
 
 	Left      *gtk.Box
 	LeftLabel *gtk.Label
+	LeftStack *gtk.Stack
 	Files     *filetree.Tree
+	VCS       *vcspanel.Panel
 
 	Right       *gtk.Box
 	RightLabel  *gtk.Label
 	RightButton *gtk.MenuButton
-	Editor      *editor.View
+	Panes       *gtk.Box
+	Tabs        *EditorTabs // the active pane
+
+	panes   []*EditorTabs
+	parents map[gtk.Widgetter]*paneSplit // nil for the root pane
+	actions map[string]func()            // bound to the page
 }
 
 // NewEditorPage creates a new EditorPage.
 func NewEditorPage(ctx context.Context) *EditorPage {
-	p := EditorPage{ctx: ctx}
+	p := EditorPage{
+		ctx:     ctx,
+		parents: make(map[gtk.Widgetter]*paneSplit),
+	}
 	p.Fold = adaptive.NewFold(gtk.PosLeft)
 	p.Fold.SetFoldThreshold(600)
 	p.Fold.SetFoldWidth(250)
@@ -78,6 +98,8 @@ func NewEditorPage(ctx context.Context) *EditorPage {
 		gtkutil.MenuItem("_Open", "win.open"),
 		gtkutil.MenuItem("Open a _Copy", "win.open-copy"),
 		gtkutil.MenuItem("_Refresh Folder", "win.refresh"),
+		gtkutil.MenuItem("_Go to File...", "win.quick-open"),
+		gtkutil.MenuItem("_Command Palette...", "win.command-palette"),
 		gtkutil.MenuItem("Back to _Home", "win.switch-to-greeter"),
 		gtkutil.MenuSeparator(""),
 		gtkutil.MenuItem("_Preferences", "app.preferences"),
@@ -95,14 +117,34 @@ func NewEditorPage(ctx context.Context) *EditorPage {
 
 	p.Files = filetree.NewTree(ctx)
 	p.Files.ConnectFileActivated(func(path string) {
-		p.Editor.Load(path)
-		p.RightLabel.SetText(p.Files.RelPath(path))
+		p.Tabs.Open(path)
 	})
+	p.Files.ConnectFileMoved(p.relocateFiles)
+	p.Files.ConnectFileRemoved(p.closeRemovedFiles)
+
+	p.VCS = vcspanel.NewPanel(ctx)
+	p.VCS.ConnectFileActivated(func(path string) {
+		p.Tabs.Open(path)
+	})
+	p.VCS.ConnectChanged(p.reloadHeads)
+	p.Files.ConnectFilesChanged(p.VCS.QueueRefresh)
+
+	p.LeftStack = gtk.NewStack()
+	p.LeftStack.SetVExpand(true)
+	p.LeftStack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	p.LeftStack.AddTitled(p.Files, "files", "Files")
+	p.LeftStack.AddTitled(p.VCS, "vcs", "Version Control")
+
+	leftSwitcher := gtk.NewStackSwitcher()
+	leftSwitcher.SetHAlign(gtk.AlignCenter)
+	leftSwitcher.AddCSSClass("main-left-switcher")
+	leftSwitcher.SetStack(p.LeftStack)
 
 	p.Left = gtk.NewBox(gtk.OrientationVertical, 0)
 	p.Left.AddCSSClass("main-left")
 	p.Left.Append(leftHeader)
-	p.Left.Append(p.Files)
+	p.Left.Append(leftSwitcher)
+	p.Left.Append(p.LeftStack)
 
 	/*
 	 * Right
@@ -123,7 +165,13 @@ func NewEditorPage(ctx context.Context) *EditorPage {
 	p.RightButton.SetMenuModel(gtkutil.CustomMenuItems(
 		gtkutil.MenuItem("Save", "editor.save"),
 		gtkutil.MenuItem("Save As...", "editor.save-as"), // TODO
-		gtkutil.MenuItem("Print...", "editor.print"),     // TODO
+		gtkutil.MenuItem("Print...", "editor.print"),
+		gtkutil.MenuItem("Export as HTML...", "editor.export-html"),
+		gtkutil.MenuItem("History...", "editor.history"),
+		gtkutil.MenuSeparator(""),
+		gtkutil.MenuItem("Split Right", "pane.split-right"),
+		gtkutil.MenuItem("Split Down", "pane.split-down"),
+		gtkutil.MenuItem("Close Pane", "pane.close"),
 		gtkutil.MenuSeparator(""),
 		gtkutil.MenuItem("Find...", "editor.find"),                         // TODO
 		gtkutil.MenuItem("Find and Replace...", "editor.find-and-replace"), // TODO
@@ -134,6 +182,25 @@ func NewEditorPage(ctx context.Context) *EditorPage {
 		gtkutil.MenuSeparator(""),
 		gtkutil.MenuItem("Move to Matching Bracket", "editor.move-to-matching-bracket"),
 		gtkutil.MenuSeparator(""),
+		gtkutil.Submenu("Format...", []gtkutil.PopoverMenuItem{
+			gtkutil.MenuItem("Bold", "editor.bold"),
+			gtkutil.MenuItem("Italic", "editor.italic"),
+			gtkutil.MenuItem("Code", "editor.code"),
+			gtkutil.MenuItem("Link", "editor.link"),
+			gtkutil.MenuSeparator(""),
+			gtkutil.MenuItem("Heading 1", "editor.heading-1"),
+			gtkutil.MenuItem("Heading 2", "editor.heading-2"),
+			gtkutil.MenuItem("Heading 3", "editor.heading-3"),
+			gtkutil.MenuSeparator(""),
+			gtkutil.MenuItem("Quote", "editor.quote"),
+			gtkutil.MenuItem("Bulleted List", "editor.bulleted-list"),
+			gtkutil.MenuItem("Numbered List", "editor.numbered-list"),
+		}),
+		gtkutil.Submenu("Table...", []gtkutil.PopoverMenuItem{
+			gtkutil.MenuItem("Format Table", "editor.format-table"),
+			gtkutil.MenuItem("Insert Row", "editor.insert-table-row"),
+			gtkutil.MenuItem("Insert Column", "editor.insert-table-column"),
+		}),
 		gtkutil.Submenu("Change Case...", []gtkutil.PopoverMenuItem{
 			gtkutil.MenuItem("To Lower", "editor.change-case-lower"),
 			gtkutil.MenuItem("To Upper", "editor.change-case-lower"),
@@ -150,13 +217,30 @@ func NewEditorPage(ctx context.Context) *EditorPage {
 	rightHeader.PackEnd(gtk.NewWindowControls(gtk.PackEnd))
 	rightHeader.PackEnd(p.RightButton)
 
-	p.Editor = editor.NewView(ctx, (*editorController)(&p))
-	gtkutil.BindActionMap(p, p.Editor.ActionFuncs())
+	p.setActivePane(p.newPane())
+	p.actions = editor.ActionFuncsFor(func() *editor.View {
+		return p.Tabs.Current()
+	})
+	paneActions := map[string]func(){
+		"pane.split-right": func() { p.Split(gtk.OrientationHorizontal) },
+		"pane.split-down":  func() { p.Split(gtk.OrientationVertical) },
+		"pane.close":       p.ClosePane,
+	}
+	for name, f := range paneActions {
+		p.actions[name] = f
+	}
+	gtkutil.BindActionMap(p, p.actions)
+	// Editor actions are bound by each View.
+	keymap.BindActions(p, paneActions)
+
+	p.Panes = gtk.NewBox(gtk.OrientationVertical, 0)
+	p.Panes.SetVExpand(true)
+	p.Panes.Append(p.Tabs)
 
 	p.Right = gtk.NewBox(gtk.OrientationVertical, 0)
 	p.Right.AddCSSClass("main-right")
 	p.Right.Append(rightHeader)
-	p.Right.Append(p.Editor)
+	p.Right.Append(p.Panes)
 
 	/*
 	 * Finalize
@@ -172,14 +256,26 @@ func emptyWidget() gtk.Widgetter {
 	return gtk.NewBox(gtk.OrientationHorizontal, 0)
 }
 
-// AskBufferDestroy implements editor.Controller.
+// AskBufferDestroy asks the user before discarding the unsaved changes of all
+// open tabs. do is called if the user agrees or if there's nothing to discard.
 func (p *EditorPage) AskBufferDestroy(do func()) {
-	if !p.Editor.IsUnsaved() {
+	if !p.IsUnsaved() {
 		glib.IdleAdd(do)
 		return
 	}
 
-	window := app.WindowFromContext(p.ctx)
+	askDiscard(p.ctx, func() {
+		for _, pane := range p.panes {
+			pane.DiscardChanges()
+		}
+		do()
+	})
+}
+
+// askDiscard asks the user whether or not unsaved changes should be thrown
+// away. do is called if the user agrees.
+func askDiscard(ctx context.Context, do func()) {
+	window := app.WindowFromContext(ctx)
 	dialog := gtk.NewMessageDialog(
 		&window.Window,
 		gtk.DialogDestroyWithParent|gtk.DialogModal|gtk.DialogUseHeaderBar,
@@ -197,7 +293,6 @@ func (p *EditorPage) AskBufferDestroy(do func()) {
 
 	dialog.ConnectResponse(func(resp int) {
 		if resp == int(gtk.ResponseYes) {
-			p.Editor.DiscardChanges()
 			do()
 		}
 		dialog.Destroy()
@@ -205,36 +300,47 @@ func (p *EditorPage) AskBufferDestroy(do func()) {
 	dialog.Show()
 }
 
-// Load loads the given file or folder.
+// Load loads the given file or folder. A file anywhere within the current
+// folder is opened in a new tab; anything else closes all tabs. The session of the
+// current folder is saved, and a loaded folder's saved session is restored.
 func (p *EditorPage) Load(path string) {
-	p.AskBufferDestroy(func() {
-		gtkutil.Async(p.ctx, func() func() {
-			s, err := os.Stat(path)
-			if err != nil {
-				app.Error(p.ctx, err)
-				return nil
+	gtkutil.Async(p.ctx, func() func() {
+		s, err := os.Stat(path)
+		if err != nil {
+			app.Error(p.ctx, err)
+			return nil
+		}
+
+		return func() {
+			if root := p.Files.Path(); root != "" && !s.IsDir() && filetree.IsWithin(path, root) {
+				p.Tabs.Open(path)
+				return
 			}
 
-			return func() {
+			p.AskBufferDestroy(func() {
+				p.SaveSession()
+
 				if s.IsDir() {
 					p.loadFolder(path)
+					p.restoreSession(path)
 				} else {
 					p.loadFile(path)
 				}
-			}
-		})
+			})
+		}
 	})
 }
 
 func (p *EditorPage) loadFile(path string) {
 	dir := filepath.Dir(path)
+	p.closeAll()
 	p.Files.Load(dir)
+	p.VCS.Load(dir)
 	p.LeftLabel.SetText(formatPath(dir))
 	p.selectFile(path)
 }
 
 func (p *EditorPage) selectFile(path string) {
-	p.Editor.DiscardChanges()
 	if path != "" {
 		p.Files.SelectPath(path)
 	}
@@ -245,9 +351,21 @@ func (p *EditorPage) loadFolder(path string) {
 		return
 	}
 
+	p.closeAll()
 	p.Files.Load(path)
+	p.VCS.Load(path)
 	p.LeftLabel.SetText(formatPath(path))
-	p.Editor.DiscardChanges()
+}
+
+// reloadHeads updates everything that depends on the repository after it was
+// changed through the version control panel.
+func (p *EditorPage) reloadHeads() {
+	p.Files.RefreshStatus()
+	for _, pane := range p.panes {
+		for _, view := range pane.Views() {
+			view.ReloadHead()
+		}
+	}
 }
 
 func formatPath(path string) string {
@@ -270,22 +388,19 @@ func (p *EditorPage) OpenCopy() {
 	w.Show()
 }
 
-type editorController EditorPage
-
 const unsavedMark = " " + filetree.UnsavedDot
 
-// InvalidateUnsaved implements editor.Controller.
-func (c *editorController) InvalidateUnsaved() {
-	name := c.Files.RelPath(c.Editor.Path())
-	if c.Editor.IsUnsaved() {
-		c.RightLabel.SetText(name + unsavedMark)
-		c.Files.SetUnsaved(name, true)
-	} else {
-		c.RightLabel.SetText(name)
-		c.Files.SetUnsaved(name, false)
+// updateTitle updates the header to show the file of the current tab.
+func (p *EditorPage) updateTitle() {
+	view := p.Tabs.Current()
+	if view == nil {
+		p.RightLabel.SetText("")
+		return
 	}
-}
 
-func (c *editorController) AskBufferDestroy(do func()) {
-	(*EditorPage)(c).AskBufferDestroy(do)
+	name := p.Files.RelPath(view.Path())
+	if view.IsUnsaved() {
+		name += unsavedMark
+	}
+	p.RightLabel.SetText(name)
 }
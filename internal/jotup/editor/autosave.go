@@ -0,0 +1,96 @@
+package editor
+
+import (
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/prefs"
+)
+
+// Autosave modes.
+const (
+	autosaveOff      = "Off"
+	autosaveIdle     = "After Idle"
+	autosaveFocusOut = "On Focus Out"
+)
+
+var autosaveMode = prefs.NewEnumList(autosaveOff, prefs.EnumListMeta{
+	PropMeta: prefs.PropMeta{
+		Name:        "Autosave",
+		Section:     "Autosave",
+		Description: "Automatically save files after being idle or when the editor loses focus.",
+	},
+	Options: []string{autosaveOff, autosaveIdle, autosaveFocusOut},
+})
+
+var autosaveDelay = prefs.NewInt(5, prefs.IntMeta{
+	Name:        "Idle Delay",
+	Section:     "Autosave",
+	Description: "The number of seconds without typing before the file is autosaved.",
+	Min:         1,
+	Max:         600,
+})
+
+// bindAutosave binds the autosave triggers to the View.
+func (v *View) bindAutosave() {
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectLeave(func() { v.autosaveOn(autosaveFocusOut) })
+	v.Source.AddController(focus)
+
+	// Also catch the user switching away from the whole window, which doesn't
+	// move the focus within it.
+	if window := app.WindowFromContext(v.ctx); window != nil {
+		v.window = window
+		v.windowHandle = window.NotifyProperty("is-active", func() {
+			if !window.IsActive() {
+				v.autosaveOn(autosaveFocusOut)
+			}
+		})
+	}
+
+	autosaveMode.SubscribeWidget(v.Source, v.stopAutosaveTimer)
+}
+
+// unbindAutosave disconnects the View from the window, which outlives it.
+func (v *View) unbindAutosave() {
+	if v.window != nil {
+		v.window.HandlerDisconnect(v.windowHandle)
+		v.window = nil
+	}
+}
+
+// queueAutosave restarts the idle autosave timer if autosaving after idle is
+// enabled. It is called on every edit.
+func (v *View) queueAutosave() {
+	v.stopAutosaveTimer()
+
+	if autosaveMode.Value() != autosaveIdle {
+		return
+	}
+
+	v.autosaveHandle = glib.TimeoutSecondsAdd(uint(autosaveDelay.Value()), func() {
+		v.autosaveHandle = 0
+		v.live().autosaveOn(autosaveIdle)
+	})
+}
+
+func (v *View) stopAutosaveTimer() {
+	if v.autosaveHandle > 0 {
+		glib.SourceRemove(v.autosaveHandle)
+		v.autosaveHandle = 0
+	}
+}
+
+// autosaveOn saves the file if the autosave mode matches the given mode. It
+// does nothing if there's nothing to save or a save is already in flight.
+func (v *View) autosaveOn(mode string) {
+	if autosaveMode.Value() != mode {
+		return
+	}
+
+	if v.saving || !v.unsaved || v.untrack || v.path == "" {
+		return
+	}
+
+	v.save(nil)
+}
@@ -0,0 +1,277 @@
+package editor
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4-sourceview/pkg/gtksource/v5"
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
+	"github.com/go-git/go-git/v5"
+)
+
+// diffDelay is the delay in milliseconds after the last keystroke before the
+// diff markers are updated.
+const diffDelay = 500
+
+var diffPopoverCSS = cssutil.Applier("editor-diff-popover", `
+	.editor-diff-popover > contents {
+		padding: 6px;
+	}
+	.editor-diff-popover label {
+		font-family: monospace;
+	}
+`)
+
+// bindDiffGutter adds the gutter showing the lines changed since HEAD.
+func (v *View) bindDiffGutter() {
+	v.diffGutter = gtksource.NewGutterRendererText()
+	v.diffGutter.SetSizeRequest(6, -1)
+	v.diffGutter.SetXAlign(0)
+	v.diffGutter.ConnectQueryData(func(_ *glib.Object, line uint) {
+		hunk := v.hunkAt(int(line))
+		if hunk == nil {
+			v.diffGutter.SetText("", -1)
+			return
+		}
+
+		var marker string
+		switch hunk.Kind {
+		case vcs.HunkAdded:
+			marker = `<span color="#26a269">▎</span>`
+		case vcs.HunkModified:
+			marker = `<span color="#e5a50a">▎</span>`
+		case vcs.HunkDeleted:
+			if hunk.Start == 0 {
+				marker = `<span color="#e01b24">▔</span>`
+			} else {
+				marker = `<span color="#e01b24">▁</span>`
+			}
+		}
+
+		v.diffGutter.SetMarkup(marker, -1)
+	})
+	v.diffGutter.ConnectQueryActivatable(func(iter *gtk.TextIter, _ *gdk.Rectangle) bool {
+		return v.hunkAt(iter.Line()) != nil
+	})
+	v.diffGutter.ConnectActivate(func(iter *gtk.TextIter, area *gdk.Rectangle, _ uint, _ gdk.ModifierType, _ int) {
+		if hunk := v.hunkAt(iter.Line()); hunk != nil {
+			v.showHunk(*hunk, area)
+		}
+	})
+
+	gutter := v.Source.Gutter(gtk.TextWindowLeft)
+	gutter.Insert(v.diffGutter, 0)
+}
+
+// hunkAt returns the hunk shown at the given line or nil if there's none.
+func (v *View) hunkAt(line int) *vcs.Hunk {
+	for i, hunk := range v.hunks {
+		if hunk.Contains(line) {
+			return &v.hunks[i]
+		}
+	}
+	return nil
+}
+
+// loadHead loads the file's contents as of HEAD, which the buffer is diffed
+// against. Files that aren't committed have no diff markers.
+func (v *View) loadHead() {
+	path := v.path
+
+	gtkutil.Async(v.ctx, func() func() {
+		head, err := headContents(path)
+		if err != nil {
+			log.Println("cannot get HEAD contents:", err)
+		}
+
+		return func() {
+			// Make sure we're still on the same file.
+			if v.path != path {
+				return
+			}
+
+			v.head = head
+			v.updateDiff()
+		}
+	})
+}
+
+// ReloadHead reloads the file's contents as of HEAD, such as after a commit.
+func (v *View) ReloadHead() {
+	v.loadHead()
+}
+
+// headContents returns the contents of the file at HEAD or nil if the file
+// isn't committed.
+func headContents(path string) (*string, error) {
+	repo, err := vcs.Open(filepath.Dir(path))
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	head, err := repo.HeadContents(path)
+	if err != nil {
+		if errors.Is(err, vcs.ErrNotCommitted) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	return &head, nil
+}
+
+// queueDiff updates the diff markers once the user stops typing.
+func (v *View) queueDiff() {
+	if v.head == nil {
+		return
+	}
+
+	if v.diffHandle > 0 {
+		glib.SourceRemove(v.diffHandle)
+	}
+	v.diffHandle = glib.TimeoutAdd(diffDelay, func() {
+		v.diffHandle = 0
+		v.live().updateDiff()
+	})
+}
+
+// updateDiff asynchronously diffs the buffer against HEAD. Diffs that finish
+// after a newer one started are dropped.
+func (v *View) updateDiff() {
+	v.diffGen++
+
+	if v.head == nil {
+		v.setHunks(nil)
+		return
+	}
+
+	head := *v.head
+	text := v.Buffer.Text(v.Buffer.StartIter(), v.Buffer.EndIter(), true)
+	path := v.path
+	gen := v.diffGen
+
+	gtkutil.Async(v.ctx, func() func() {
+		hunks := vcs.DiffLines(head, text)
+
+		return func() {
+			if v.diffGen == gen && v.path == path {
+				v.setHunks(hunks)
+			}
+		}
+	})
+}
+
+// diffNow diffs the buffer against HEAD right away, dropping any diff that's
+// still running.
+func (v *View) diffNow() {
+	v.diffGen++
+
+	if v.diffHandle > 0 {
+		glib.SourceRemove(v.diffHandle)
+		v.diffHandle = 0
+	}
+
+	if v.head == nil {
+		v.setHunks(nil)
+		return
+	}
+
+	text := v.Buffer.Text(v.Buffer.StartIter(), v.Buffer.EndIter(), true)
+	v.setHunks(vcs.DiffLines(*v.head, text))
+}
+
+func (v *View) setHunks(hunks []vcs.Hunk) {
+	v.hunks = hunks
+	for _, view := range v.views {
+		view.diffGutter.QueueDraw()
+	}
+}
+
+// showHunk shows a popover with the original lines of the hunk, pointing to
+// the given area of the gutter.
+func (v *View) showHunk(hunk vcs.Hunk, area *gdk.Rectangle) {
+	var original *gtk.Label
+	if hunk.Old == "" {
+		original = gtk.NewLabel("Added lines.")
+		original.AddCSSClass("dim-label")
+	} else {
+		original = gtk.NewLabel(strings.TrimSuffix(hunk.Old, "\n"))
+		original.SetSelectable(true)
+		original.SetWrap(false)
+		original.SetXAlign(0)
+	}
+
+	scroll := gtk.NewScrolledWindow()
+	scroll.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	scroll.SetPropagateNaturalWidth(true)
+	scroll.SetPropagateNaturalHeight(true)
+	scroll.SetMaxContentWidth(600)
+	scroll.SetMaxContentHeight(300)
+	scroll.SetChild(original)
+
+	popover := gtk.NewPopover()
+
+	revert := gtk.NewButtonWithLabel("Revert Hunk")
+	revert.SetHAlign(gtk.AlignEnd)
+	revert.SetSensitive(v.Source.Editable())
+	revert.ConnectClicked(func() {
+		popover.Popdown()
+		v.revertHunk(hunk)
+	})
+
+	box := gtk.NewBox(gtk.OrientationVertical, 6)
+	box.Append(scroll)
+	box.Append(revert)
+
+	popover.SetChild(box)
+	popover.SetPosition(gtk.PosRight)
+	popover.SetParent(v.diffGutter)
+	popover.SetPointingTo(area)
+	diffPopoverCSS(popover)
+	gtkutil.PopupFinally(popover)
+}
+
+// revertHunk replaces the lines of the hunk with their original contents. The
+// shown hunks may be behind the buffer, so it's diffed again first, and the
+// hunk is only reverted if it still has the same original lines.
+func (v *View) revertHunk(hunk vcs.Hunk) {
+	v.diffNow()
+
+	current, ok := v.sameHunk(hunk)
+	if !ok {
+		v.Toast.Show("The hunk has changed since it was shown.")
+		return
+	}
+	hunk = current
+
+	// Lines past the last one give the end of the buffer.
+	start, _ := v.Buffer.IterAtLine(hunk.Start)
+	end, _ := v.Buffer.IterAtLine(hunk.Start + hunk.Lines)
+
+	v.Buffer.BeginUserAction()
+	v.Buffer.Delete(start, end)
+	v.Buffer.Insert(start, hunk.Old)
+	v.Buffer.EndUserAction()
+}
+
+// sameHunk returns the current hunk at the same place with the same original
+// lines as the given one. Its changed lines may differ. False is returned if
+// there's none.
+func (v *View) sameHunk(hunk vcs.Hunk) (vcs.Hunk, bool) {
+	for _, current := range v.hunks {
+		if current.Start == hunk.Start && current.Kind == hunk.Kind && current.Old == hunk.Old {
+			return current, true
+		}
+	}
+	return vcs.Hunk{}, false
+}
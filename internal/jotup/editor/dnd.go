@@ -0,0 +1,56 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/jotup/internal/jotup/fileutil"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// bindFileDrop makes files dropped into the editor insert a Markdown link to
+// them, or an image if they're images.
+func (v *View) bindFileDrop() {
+	drop := gtk.NewDropTarget(gio.GTypeFile, gdk.ActionCopy)
+	drop.ConnectDrop(func(value coreglib.Value, x, y float64) bool {
+		path, ok := fileutil.DroppedPath(&value)
+		if !ok || v.path == "" || !v.Source.Editable() {
+			return false
+		}
+
+		bx, by := v.Source.WindowToBufferCoords(gtk.TextWindowWidget, int(x), int(y))
+		iter, _ := v.Source.IterAtLocation(bx, by)
+
+		v.Buffer.PlaceCursor(iter)
+		v.Buffer.InsertAtCursor(fileLink(v.path, path))
+		v.Source.GrabFocus()
+		return true
+	})
+	v.Source.AddController(drop)
+}
+
+// fileLink returns the Markdown link to the file at path, relative to the
+// directory of the Markdown file at from.
+func fileLink(from, path string) string {
+	dst, err := filepath.Rel(filepath.Dir(from), path)
+	if err != nil {
+		dst = path
+	}
+
+	dst = filepath.ToSlash(dst)
+	if strings.ContainsAny(dst, " ()") {
+		dst = "<" + dst + ">"
+	}
+
+	if fileutil.IsImage(path) {
+		return "![](" + dst + ")"
+	}
+
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	return "[" + name + "](" + dst + ")"
+}
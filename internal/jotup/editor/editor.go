@@ -11,10 +11,13 @@ import (
 	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/prefs"
-	"github.com/diamondburned/gotkit/gtkutil"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/jotup/internal/jotup/components/toast"
+	"github.com/diamondburned/jotup/internal/jotup/editor/mdedit"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
 
 	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
 )
@@ -114,6 +117,15 @@ type Controller interface {
 	// AskBufferDestroy informs the user for a potentially destructive buffer
 	// action because of unsaved changes.
 	AskBufferDestroy(destroy func())
+	// OpenPath opens the given file or folder, which may be relative to the
+	// opened folder, such as for Vim's :e command.
+	OpenPath(path string)
+	// ResolvePath returns the absolute path of a path typed by the user, which
+	// may be relative to the opened folder.
+	ResolvePath(path string) string
+	// InvalidatePath indicates that the View now edits a file at another
+	// path, such as after a linked View loaded another file.
+	InvalidatePath()
 }
 
 // View is a Markdown editor sandwiched with a Markdown preview.
@@ -125,19 +137,49 @@ type View struct {
 	Source  *gtksource.View
 	Preview *gtk.Box
 
+	scroll     *gtk.ScrolledWindow
+	diffGutter *gtksource.GutterRendererText
+
 	Minimap *gtksource.Map
 	Buffer  *gtksource.Buffer
 	File    *gtksource.File
 
 	progrev  *gtk.Revealer
 	progress *gtk.ProgressBar
+	vim      vimState // Vim mode
+
+	window       *app.Window // for autosaving on focus loss
+	windowHandle glib.SignalHandle
 
 	ctx  context.Context
 	ctrl Controller
+	*document
+}
+
+// document is the state of the edited file. It is shared by all Views that
+// edit the same buffer.
+type document struct {
+	views []*View
 
 	path    string
 	untrack bool // only change within AskBufferDestroy
 	unsaved bool
+	saving  bool
+	dirtied bool // edited while saving
+
+	monitor       *gio.FileMonitor
+	monitorHandle glib.SourceHandle
+	keepMine      bool // overwrite external changes on next save
+
+	autosaveHandle glib.SourceHandle
+	swapHandle     glib.SourceHandle
+
+	printSettings *gtk.PrintSettings // of the last print, for the next one
+
+	head       *string // contents at HEAD, nil if not committed
+	hunks      []vcs.Hunk
+	diffHandle glib.SourceHandle
+	diffGen    uint // of the latest diff, so that older ones are dropped
 }
 
 var loadingCSS = cssutil.Applier("editor-loading", `
@@ -167,10 +209,28 @@ var minimapCSS = cssutil.Applier("editor-minimap", `
 
 // NewView creates a new View.
 func NewView(ctx context.Context, ctrl Controller) *View {
-	v := View{ctx: ctx, ctrl: ctrl}
-	v.File = gtksource.NewFile()
-	v.Buffer = gtksource.NewBuffer(nil)
-	v.Buffer.ConnectChanged(func() { v.markEdited(true) })
+	v := newView(ctx, ctrl, gtksource.NewBuffer(nil), gtksource.NewFile(), &document{})
+	v.Buffer.ConnectChanged(func() {
+		live := v.live()
+		live.markEdited(true)
+		live.queueDiff()
+	})
+	return v
+}
+
+// NewLinkedView creates a new View that edits the same buffer as the given
+// View. All changes, including saving and loading, apply to both.
+func NewLinkedView(ctx context.Context, ctrl Controller, other *View) *View {
+	v := newView(ctx, ctrl, other.Buffer, other.File, other.document)
+	v.Source.SetEditable(other.Source.Editable())
+	return v
+}
+
+func newView(ctx context.Context, ctrl Controller, buffer *gtksource.Buffer, file *gtksource.File, doc *document) *View {
+	v := View{ctx: ctx, ctrl: ctrl, document: doc}
+	v.File = file
+	v.Buffer = buffer
+	v.views = append(v.views, &v)
 
 	v.Toast = toast.NewToast(gtk.PackStart)
 	v.Toast.SetLog(true)
@@ -221,6 +281,7 @@ func NewView(ctx context.Context, ctrl Controller) *View {
 	textScroll.SetVExpand(true)
 	textScroll.SetHExpand(true)
 	textScroll.SetChild(v.Source)
+	v.scroll = textScroll
 
 	minimapBox := gtk.NewBox(gtk.OrientationHorizontal, 0)
 	minimapBox.Append(textScroll)
@@ -289,12 +350,34 @@ func NewView(ctx context.Context, ctrl Controller) *View {
 	v.Overlay.AddOverlay(v.Toast)
 	v.Overlay.SetChild(v.Box)
 
-	gtkutil.BindKeys(v, map[string]func() bool{
-		"<Ctrl>S": func() bool {
-			v.Save()
-			return true
-		},
+	// File actions work anywhere in the view, but editing ones only in the
+	// source, since the preview has its own selections.
+	keymap.BindActions(v, map[string]func(){
+		"editor.save":        v.Save,
+		"editor.print":       v.Print,
+		"editor.export-html": v.ExportHTML,
+		"editor.history":     v.ShowHistory,
 	})
+	sourceKeys := make(map[string]func() bool, len(viewActions))
+	for name, f := range viewActions {
+		f := f
+		sourceKeys[name] = func() bool {
+			// Vim has its own keys for editing.
+			if v.vimEnabled() {
+				return false
+			}
+			f(&v)
+			return true
+		}
+	}
+	keymap.BindKeys(v.Source, sourceKeys)
+
+	v.bindAutosave()
+	v.bindFileDrop()
+	v.bindDiffGutter()
+	v.bindVim()
+	v.bindToolbar()
+	v.bindListKeys()
 
 	return &v
 }
@@ -329,6 +412,11 @@ func (v *View) SetOrientation(orientation gtk.Orientation) {
 	v.Box.SetOrientation(orientation)
 }
 
+// IsLinked returns true if other Views are editing the same buffer.
+func (v *View) IsLinked() bool {
+	return len(v.views) > 1
+}
+
 // Path returns the View's path to the currently edited file.
 func (v *View) Path() string {
 	return v.path
@@ -339,12 +427,72 @@ func (v *View) Load(path string) {
 	v.LoadFile(gio.NewFileForPath(path))
 }
 
-// LoadFile asynchronously loads the gio.File into the buffer.
+// LoadAt is like Load, except the given position is restored once the file is
+// loaded.
+func (v *View) LoadAt(path string, pos Position) {
+	v.loadFile(gio.NewFileForPath(path), func() { v.SetPosition(pos) })
+}
+
+// LoadFile asynchronously loads the gio.File into the buffer. Linked Views are
+// switched to the new file as well.
 func (v *View) LoadFile(file gio.Filer) {
+	v.loadFile(file, nil)
+}
+
+func (v *View) loadFile(file gio.Filer, done func()) {
 	v.ctrl.AskBufferDestroy(func() {
 		v.path = file.Path()
+		v.keepMine = false
 		v.File.SetLocation(file)
-		v.Refresh()
+		v.monitorFile(file)
+		v.invalidatePath()
+		v.refresh(done)
+	})
+}
+
+// Relocate changes the path of the edited file after it was moved on disk. The
+// buffer is kept as-is, including its unsaved changes.
+func (v *View) Relocate(path string) {
+	if v.path == path {
+		return
+	}
+
+	v.removeSwap()
+
+	file := gio.NewFileForPath(path)
+	v.path = path
+	v.File.SetLocation(file)
+	v.monitorFile(file)
+	v.invalidatePath()
+	v.loadHead()
+
+	if v.unsaved {
+		v.queueSwap()
+	}
+}
+
+// Position describes the cursor and scroll position of a View.
+type Position struct {
+	Cursor int     `json:"cursor"` // in characters
+	Scroll float64 `json:"scroll"` // in pixels
+}
+
+// Position returns the current cursor and scroll position.
+func (v *View) Position() Position {
+	return Position{
+		Cursor: v.Buffer.IterAtMark(v.Buffer.GetInsert()).Offset(),
+		Scroll: v.scroll.VAdjustment().Value(),
+	}
+}
+
+// SetPosition moves the cursor and scrolls to the given position.
+func (v *View) SetPosition(pos Position) {
+	v.Buffer.PlaceCursor(v.Buffer.IterAtOffset(pos.Cursor))
+
+	// The text might not be laid out yet, so wait until it is before
+	// scrolling.
+	glib.IdleAdd(func() {
+		v.scroll.VAdjustment().SetValue(pos.Scroll)
 	})
 }
 
@@ -361,15 +509,34 @@ func (v *View) UnsetBusy() {
 	v.progrev.SetRevealChild(false)
 }
 
+// setAllBusy sets all linked Views into a busy state while the document is
+// loading or saving.
+func (v *View) setAllBusy(disable bool) {
+	for _, view := range v.views {
+		view.SetBusy(disable)
+	}
+}
+
+func (v *View) unsetAllBusy() {
+	for _, view := range v.views {
+		view.UnsetBusy()
+	}
+}
+
 // Refresh refreshes the editor to reload the current file.
 func (v *View) Refresh() {
+	v.refresh(nil)
+}
+
+func (v *View) refresh(done func()) {
 	v.untrack = true
-	v.SetBusy(true)
+	v.setAllBusy(true)
 
 	loader := gtksource.NewFileLoader(v.Buffer, v.File)
 	loader.LoadAsync(v.ctx, int(glib.PriorityHigh), nil, func(result gio.AsyncResulter) {
+		v := v.live()
 		defer func() {
-			v.UnsetBusy()
+			v.unsetAllBusy()
 			v.untrack = false
 		}()
 
@@ -385,7 +552,14 @@ func (v *View) Refresh() {
 		file := v.File.Location()
 		langman := gtksource.LanguageManagerGetDefault()
 		v.Buffer.SetLanguage(langman.GuessLanguage(file.Basename(), ""))
-		v.Source.SetEditable(true)
+		v.setEditable(true)
+
+		v.checkSwap()
+		v.loadHead()
+
+		if done != nil {
+			done()
+		}
 	})
 }
 
@@ -395,17 +569,30 @@ func (v *View) Save() {
 }
 
 func (v *View) save(done func(error)) {
-	v.SetBusy(false)
+	v.setAllBusy(false)
+	v.saving = true
+	v.dirtied = false
+	v.stopAutosaveTimer()
 
 	saver := gtksource.NewFileSaver(v.Buffer, v.File)
+	saver.SetFlags(v.saverFlags())
 	saver.SaveAsync(v.ctx, int(glib.PriorityHigh), nil, func(result gio.AsyncResulter) {
-		v.UnsetBusy()
+		v := v.live()
+		v.unsetAllBusy()
+		v.saving = false
 
 		err := saver.SaveFinish(result)
-		if err != nil {
+		switch {
+		case err != nil:
 			v.Toast.Show("Error: " + err.Error())
-		} else {
+		case v.dirtied:
+			// The user kept typing while we were saving, so the file on disk
+			// may not have the latest changes.
+			v.queueAutosave()
+		default:
 			v.markEdited(false)
+			v.removeSwap()
+			v.keepMine = false
 		}
 
 		if done != nil {
@@ -425,47 +612,201 @@ func (v *View) Clear() {
 		v.Buffer.SetText("")
 		v.Buffer.SetLanguage(nil)
 		v.File.SetLocation(nil)
-		v.Source.SetEditable(false)
+		v.setEditable(false)
+		v.unmonitorFile()
+		v.head = nil
+		v.updateDiff() // drops any running diff
+	}
+}
+
+func (v *View) setEditable(editable bool) {
+	for _, view := range v.views {
+		view.Source.SetEditable(editable)
+	}
+}
+
+// Close stops monitoring the file and cancels all pending autosaves unless
+// other Views are still linked to it. The View should not be used afterwards.
+func (v *View) Close() {
+	v.unbindAutosave()
+
+	for i, view := range v.views {
+		if view == v {
+			v.views = append(v.views[:i], v.views[i+1:]...)
+			break
+		}
+	}
+
+	if len(v.views) > 0 {
+		return
+	}
+
+	v.unmonitorFile()
+	v.stopAutosaveTimer()
+
+	if v.swapHandle > 0 {
+		glib.SourceRemove(v.swapHandle)
+		v.swapHandle = 0
+	}
+
+	if v.diffHandle > 0 {
+		glib.SourceRemove(v.diffHandle)
+		v.diffHandle = 0
 	}
 }
 
 // DiscardChanges resets the unsaved state. The buffer isn't actually refreshed,
 // so it still contains unsaved changes. The caller should manually refresh it
-// if the buffer isn't going to be wiped.
+// if the buffer isn't going to be wiped. The crash recovery swap file is
+// removed as well.
 func (v *View) DiscardChanges() {
 	v.unsaved = false
-	v.ctrl.InvalidateUnsaved()
+	v.invalidateUnsaved()
+	v.removeSwap()
 }
 
 func (v *View) markEdited(edited bool) {
 	if !v.untrack {
 		v.unsaved = edited
-		v.ctrl.InvalidateUnsaved()
+		v.invalidateUnsaved()
+
+		if edited {
+			v.dirtied = v.saving
+			v.queueAutosave()
+			v.queueSwap()
+		}
+	}
+}
+
+// invalidateUnsaved notifies the controllers of all linked Views.
+func (v *View) invalidateUnsaved() {
+	for _, view := range v.views {
+		view.ctrl.InvalidateUnsaved()
+	}
+}
+
+// invalidatePath notifies the controllers of all linked Views that the path
+// changed.
+func (v *View) invalidatePath() {
+	for _, view := range v.views {
+		view.ctrl.InvalidatePath()
+	}
+}
+
+// live returns an open View of the document. Callbacks that outlive the View
+// that started them, such as timers and file events, must show their results
+// on it, since that View may have been closed while a linked one is still
+// open. v is returned if all Views are closed.
+func (v *View) live() *View {
+	if len(v.views) > 0 {
+		return v.views[0]
 	}
+	return v
 }
 
 // ActionFuncs returns the editor actions for a menu. The prefix is "editor.".
 func (v *View) ActionFuncs() map[string]func() {
-	emit := func(name string, args ...interface{}) func() {
-		return func() { v.Source.Emit(name, args...) }
-	}
-	return map[string]func(){
-		"editor.save":                     v.Save,
-		"editor.undo":                     func() { v.Buffer.Emit("undo") },
-		"editor.redo":                     func() { v.Buffer.Emit("redo") },
-		"editor.cut":                      emit("cut-clipboard"),
-		"editor.copy":                     emit("copy-clipboard"),
-		"editor.paste":                    emit("paste-clipboard"),
-		"editor.select-all":               emit("select-all", true),
-		"editor.unselect-all":             emit("select-all", false),
-		"editor.insert-emojis":            emit("insert-emojis"),
-		"editor.move-line-up":             emit("move-lines", false),
-		"editor.move-line-down":           emit("move-lines", true),
-		"editor.join-lines":               emit("join-lines"),
-		"editor.move-to-matching-bracket": emit("move-to-matching-bracket"),
-		"editor.change-case-lower":        emit("change-case", gtksource.SourceChangeCaseLower),
-		"editor.change-case-upper":        emit("change-case", gtksource.SourceChangeCaseUpper),
-		"editor.change-case-toggle":       emit("change-case", gtksource.SourceChangeCaseToggle),
-		"editor.change-case-title":        emit("change-case", gtksource.SourceChangeCaseTitle),
+	return ActionFuncsFor(func() *View { return v })
+}
+
+// ActionFuncsFor is like ActionFuncs, except the actions are invoked on
+// whichever View current returns at the time of activation. Nothing is done if
+// it returns nil.
+func ActionFuncsFor(current func() *View) map[string]func() {
+	actions := make(map[string]func(), len(viewActions))
+	for name, f := range viewActions {
+		f := f
+		actions[name] = func() {
+			if v := current(); v != nil {
+				f(v)
+			}
+		}
 	}
+	return actions
+}
+
+var viewActions = map[string]func(v *View){
+	"editor.save":                     (*View).Save,
+	"editor.print":                    (*View).Print,
+	"editor.export-html":              (*View).ExportHTML,
+	"editor.history":                  (*View).ShowHistory,
+	"editor.undo":                     func(v *View) { v.Buffer.Emit("undo") },
+	"editor.redo":                     func(v *View) { v.Buffer.Emit("redo") },
+	"editor.cut":                      emit("cut-clipboard"),
+	"editor.copy":                     emit("copy-clipboard"),
+	"editor.paste":                    emit("paste-clipboard"),
+	"editor.select-all":               emit("select-all", true),
+	"editor.unselect-all":             emit("select-all", false),
+	"editor.insert-emojis":            emit("insert-emojis"),
+	"editor.move-line-up":             emit("move-lines", false),
+	"editor.move-line-down":           emit("move-lines", true),
+	"editor.join-lines":               emit("join-lines"),
+	"editor.move-to-matching-bracket": emit("move-to-matching-bracket"),
+	"editor.change-case-lower":        emit("change-case", gtksource.SourceChangeCaseLower),
+	"editor.change-case-upper":        emit("change-case", gtksource.SourceChangeCaseUpper),
+	"editor.change-case-toggle":       emit("change-case", gtksource.SourceChangeCaseToggle),
+	"editor.change-case-title":        emit("change-case", gtksource.SourceChangeCaseTitle),
+	"editor.bold":                     format(mdedit.Bold),
+	"editor.italic":                   format(mdedit.Italic),
+	"editor.code":                     format(mdedit.Code),
+	"editor.link":                     format(mdedit.Link),
+	"editor.heading-1":                format(mdedit.Heading(1)),
+	"editor.heading-2":                format(mdedit.Heading(2)),
+	"editor.heading-3":                format(mdedit.Heading(3)),
+	"editor.heading-4":                format(mdedit.Heading(4)),
+	"editor.heading-5":                format(mdedit.Heading(5)),
+	"editor.heading-6":                format(mdedit.Heading(6)),
+	"editor.quote":                    format(mdedit.Quote),
+	"editor.bulleted-list":            format(mdedit.BulletedList),
+	"editor.numbered-list":            format(mdedit.NumberedList),
+	"editor.format-table":             tableEdit(mdedit.FormatTable),
+	"editor.insert-table-row":         tableEdit(mdedit.InsertRow),
+	"editor.insert-table-column":      tableEdit(mdedit.InsertColumn),
+}
+
+func init() {
+	// The clipboard, undo, selection, line and case actions have the shortcuts
+	// built into GtkSourceView, which are blocked once they're rebound.
+	keymap.Register(
+		keymap.Binding{Action: "editor.save", Label: "Save", Accels: []string{"<Ctrl>S"}},
+		keymap.Binding{Action: "editor.print", Label: "Print"},
+		keymap.Binding{Action: "editor.export-html", Label: "Export as HTML"},
+		keymap.Binding{Action: "editor.history", Label: "Show File History"},
+		keymap.Binding{Action: "editor.undo", Label: "Undo", Accels: []string{"<Ctrl>Z"}, Builtin: true},
+		keymap.Binding{Action: "editor.redo", Label: "Redo", Accels: []string{"<Ctrl><Shift>Z"}, Builtin: true},
+		keymap.Binding{Action: "editor.cut", Label: "Cut", Accels: []string{"<Ctrl>X"}, Builtin: true},
+		keymap.Binding{Action: "editor.copy", Label: "Copy", Accels: []string{"<Ctrl>C"}, Builtin: true},
+		keymap.Binding{Action: "editor.paste", Label: "Paste", Accels: []string{"<Ctrl>V"}, Builtin: true},
+		keymap.Binding{Action: "editor.select-all", Label: "Select All", Accels: []string{"<Ctrl>A"}, Builtin: true},
+		keymap.Binding{Action: "editor.unselect-all", Label: "Unselect All", Accels: []string{"<Ctrl><Shift>A"}, Builtin: true},
+		keymap.Binding{Action: "editor.insert-emojis", Label: "Insert Emoji", Accels: []string{"<Ctrl>period"}, Builtin: true},
+		keymap.Binding{Action: "editor.move-line-up", Label: "Move Line Up", Accels: []string{"<Alt>Up"}, Builtin: true},
+		keymap.Binding{Action: "editor.move-line-down", Label: "Move Line Down", Accels: []string{"<Alt>Down"}, Builtin: true},
+		keymap.Binding{Action: "editor.join-lines", Label: "Join Lines", Accels: []string{"<Ctrl>J"}, Builtin: true},
+		keymap.Binding{Action: "editor.move-to-matching-bracket", Label: "Move to Matching Bracket", Accels: []string{"<Ctrl>percent"}, Builtin: true},
+		keymap.Binding{Action: "editor.change-case-lower", Label: "Change Case to Lower", Accels: []string{"<Ctrl>U"}, Builtin: true},
+		keymap.Binding{Action: "editor.change-case-upper", Label: "Change Case to Upper", Accels: []string{"<Ctrl><Shift>U"}, Builtin: true},
+		keymap.Binding{Action: "editor.change-case-title", Label: "Change Case to Title"},
+		keymap.Binding{Action: "editor.change-case-toggle", Label: "Toggle Case", Accels: []string{"<Ctrl>asciitilde"}, Builtin: true},
+		keymap.Binding{Action: "editor.bold", Label: "Bold", Accels: []string{"<Ctrl>B"}},
+		keymap.Binding{Action: "editor.italic", Label: "Italic", Accels: []string{"<Ctrl>I"}},
+		keymap.Binding{Action: "editor.code", Label: "Code"},
+		keymap.Binding{Action: "editor.link", Label: "Link", Accels: []string{"<Ctrl>K"}},
+		keymap.Binding{Action: "editor.heading-1", Label: "Heading 1"},
+		keymap.Binding{Action: "editor.heading-2", Label: "Heading 2"},
+		keymap.Binding{Action: "editor.heading-3", Label: "Heading 3"},
+		keymap.Binding{Action: "editor.heading-4", Label: "Heading 4"},
+		keymap.Binding{Action: "editor.heading-5", Label: "Heading 5"},
+		keymap.Binding{Action: "editor.heading-6", Label: "Heading 6"},
+		keymap.Binding{Action: "editor.quote", Label: "Quote"},
+		keymap.Binding{Action: "editor.bulleted-list", Label: "Bulleted List"},
+		keymap.Binding{Action: "editor.numbered-list", Label: "Numbered List"},
+		keymap.Binding{Action: "editor.format-table", Label: "Format Table"},
+		keymap.Binding{Action: "editor.insert-table-row", Label: "Insert Table Row"},
+		keymap.Binding{Action: "editor.insert-table-column", Label: "Insert Table Column"},
+	)
+}
+
+func emit(name string, args ...interface{}) func(*View) {
+	return func(v *View) { v.Source.Emit(name, args...) }
 }
@@ -0,0 +1,117 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/extern/js/katex"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/hl"
+	"github.com/diamondburned/jotup/internal/jotup/export"
+)
+
+// mathRetryDelay is how long after failing to load KaTeX the next export or
+// print exports without math instead of trying again.
+const mathRetryDelay = time.Minute
+
+// exportMath holds the KaTeX module shared by all exports and prints. The
+// module isn't thread-safe, so the mutex must be held while using it.
+var exportMath struct {
+	sync.Mutex
+	module *katex.Module
+	failed time.Time // when the module last failed to load
+}
+
+// ExportHTML prompts the user for a destination file and exports the buffer
+// into a standalone HTML file. Unsaved changes are included.
+func (v *View) ExportHTML() {
+	if v.path == "" {
+		return
+	}
+
+	chooser := gtk.NewFileChooserNative(
+		"Export HTML", &app.WindowFromContext(v.ctx).Window,
+		gtk.FileChooserActionSave, "Export", "Cancel",
+	)
+	chooser.SetCurrentName(export.HTMLPath(filepath.Base(v.path)))
+	chooser.SetCurrentFolder(gio.NewFileForPath(filepath.Dir(v.path)))
+	chooser.ConnectResponse(func(resp int) {
+		chooser.Destroy()
+		if resp == int(gtk.ResponseAccept) {
+			v.exportHTML(chooser.File().Path())
+		}
+	})
+	chooser.Show()
+}
+
+func (v *View) exportHTML(dst string) {
+	start, end := v.Buffer.Bounds()
+	src := []byte(v.Buffer.Text(start, end, false))
+	style := hl.Style.Value()
+
+	v.SetBusy(false)
+
+	gtkutil.Async(v.ctx, func() func() {
+		err := exportHTML(v.ctx, dst, style, src)
+
+		return func() {
+			v.UnsetBusy()
+
+			if err != nil {
+				v.Toast.Show("Error: " + err.Error())
+				return
+			}
+
+			v.Toast.Show("Exported to " + filepath.Base(dst))
+		}
+	})
+}
+
+func exportHTML(ctx context.Context, dst, style string, src []byte) error {
+	exportMath.Lock()
+	defer exportMath.Unlock()
+
+	exporter, err := export.NewExporter(export.Options{
+		Style:    style,
+		StyleDir: app.FromContext(ctx).ConfigPath("styles"),
+		Math:     loadMath(), // still export without MathML if nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := exporter.Render(&out, "", src); err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, out.Bytes(), 0644)
+}
+
+// loadMath loads the KaTeX module if it isn't yet. Nil is returned if it can't
+// be loaded, in which case it's tried again after mathRetryDelay. It's loaded
+// independently of any view, since the module outlives them. exportMath must
+// be locked.
+func loadMath() *katex.Module {
+	if exportMath.module != nil || time.Since(exportMath.failed) < mathRetryDelay {
+		return exportMath.module
+	}
+
+	module, err := katex.NewModule(context.Background())
+	if err != nil {
+		log.Println("cannot load KaTeX:", err)
+		exportMath.failed = time.Now()
+		return nil
+	}
+
+	exportMath.module = module
+	return module
+}
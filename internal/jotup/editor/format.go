@@ -0,0 +1,165 @@
+package editor
+
+import (
+	"strconv"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/editor/mdedit"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+)
+
+var showToolbar = prefs.NewBool(true, prefs.PropMeta{
+	Name:        "Formatting Toolbar",
+	Section:     "Editor",
+	Description: "Show buttons for Markdown formatting above the editor.",
+})
+
+var toolbarCSS = cssutil.Applier("editor-toolbar", `
+	.editor-toolbar {
+		padding: 2px 4px;
+		border-bottom: 1px solid @borders;
+	}
+	.editor-toolbar button {
+		min-width: 28px;
+	}
+`)
+
+// Format applies the Markdown format to the lines of the selection, or of the
+// cursor, as one undoable action.
+func (v *View) Format(f mdedit.Format) {
+	if !v.Source.Editable() {
+		return
+	}
+
+	base, in := v.selectedLines()
+	v.applyBlock(base, in, f(in))
+}
+
+// selectedLines returns the offset and the block of the whole lines of the
+// selection.
+func (v *View) selectedLines() (int, mdedit.Block) {
+	start, end, _ := v.Buffer.SelectionBounds()
+
+	lineStart := start.Copy()
+	lineStart.SetLineOffset(0)
+
+	// A selection of whole lines ends at the start of the next one, which
+	// isn't part of it.
+	lineEnd := end.Copy()
+	if lineEnd.LineOffset() == 0 && lineEnd.Line() > start.Line() {
+		lineEnd.BackwardChar()
+	}
+	if !lineEnd.EndsLine() {
+		lineEnd.ForwardToLineEnd()
+	}
+
+	base := lineStart.Offset()
+	block := mdedit.Block{
+		Text:     v.Buffer.Text(lineStart, lineEnd, true),
+		SelStart: start.Offset() - base,
+		SelEnd:   end.Offset() - base,
+	}
+	if max := lineEnd.Offset() - base; block.SelEnd > max {
+		block.SelEnd = max
+	}
+
+	return base, block
+}
+
+// applyBlock replaces the block at the offset with the changed one as one
+// undoable action, then selects its selection. Only the changed part of the
+// text is replaced, so that marks elsewhere stay in place.
+func (v *View) applyBlock(offset int, in, out mdedit.Block) {
+	if in.Text != out.Text {
+		a, b := []rune(in.Text), []rune(out.Text)
+
+		prefix := 0
+		for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+			prefix++
+		}
+		suffix := 0
+		for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+			a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+			suffix++
+		}
+
+		v.Buffer.BeginUserAction()
+		start := v.Buffer.IterAtOffset(offset + prefix)
+		end := v.Buffer.IterAtOffset(offset + len(a) - suffix)
+		v.Buffer.Delete(start, end)
+		v.Buffer.Insert(start, string(b[prefix:len(b)-suffix]))
+		v.Buffer.EndUserAction()
+	}
+
+	v.Buffer.SelectRange(
+		v.Buffer.IterAtOffset(offset+out.SelStart),
+		v.Buffer.IterAtOffset(offset+out.SelEnd),
+	)
+	v.Source.ScrollMarkOnscreen(v.Buffer.GetInsert())
+}
+
+func format(f mdedit.Format) func(*View) {
+	return func(v *View) { v.Format(f) }
+}
+
+func (v *View) bindToolbar() {
+	button := func(icon, label, action string) *gtk.Button {
+		b := gtk.NewButton()
+		if icon != "" {
+			b.SetIconName(icon)
+		} else {
+			b.SetLabel(label)
+		}
+		b.SetHasFrame(false)
+		b.SetFocusOnClick(false)
+		tooltip := keymap.Label(action)
+		if accel := keymap.AccelLabel(action); accel != "" {
+			tooltip += " (" + accel + ")"
+		}
+		b.SetTooltipText(tooltip)
+		b.ConnectClicked(func() {
+			viewActions[action](v)
+			v.Source.GrabFocus()
+		})
+		return b
+	}
+
+	headings := gtk.NewBox(gtk.OrientationVertical, 0)
+	headingMenu := gtk.NewPopover()
+	headingMenu.AddCSSClass("menu")
+	headingMenu.SetChild(headings)
+
+	for level := 1; level <= 6; level++ {
+		action := "editor.heading-" + strconv.Itoa(level)
+		b := button("", keymap.Label(action), action)
+		b.ConnectClicked(headingMenu.Popdown)
+		headings.Append(b)
+	}
+
+	heading := gtk.NewMenuButton()
+	heading.SetLabel("H")
+	heading.SetHasFrame(false)
+	heading.SetTooltipText("Heading")
+	heading.SetPopover(headingMenu)
+
+	toolbar := gtk.NewBox(gtk.OrientationHorizontal, 2)
+	toolbar.Append(button("format-text-bold-symbolic", "", "editor.bold"))
+	toolbar.Append(button("format-text-italic-symbolic", "", "editor.italic"))
+	toolbar.Append(button("", "</>", "editor.code"))
+	toolbar.Append(button("insert-link-symbolic", "", "editor.link"))
+	toolbar.Append(gtk.NewSeparator(gtk.OrientationVertical))
+	toolbar.Append(heading)
+	toolbar.Append(button("", "❝", "editor.quote"))
+	toolbar.Append(button("view-list-bullet-symbolic", "", "editor.bulleted-list"))
+	toolbar.Append(button("view-list-ordered-symbolic", "", "editor.numbered-list"))
+	toolbarCSS(toolbar)
+
+	v.Box.Prepend(toolbar)
+
+	// Subscribe on the source, since a hidden toolbar isn't mapped.
+	showToolbar.SubscribeWidget(v.Source, func() {
+		toolbar.SetVisible(showToolbar.Value())
+	})
+}
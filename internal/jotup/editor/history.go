@@ -0,0 +1,419 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/gotkit/gtkutil/textutil"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
+	"github.com/go-git/go-git/v5"
+)
+
+// revisionDate is the layout of the dates shown in the history.
+const revisionDate = "Jan 2, 2006 15:04"
+
+var historyCSS = cssutil.Applier("editor-history", `
+	.editor-history-revision {
+		padding: 4px 6px;
+	}
+	.editor-history-revision .editor-history-summary {
+		font-weight: bold;
+	}
+	.editor-history-sides > textview {
+		padding: 4px;
+	}
+	.editor-history-sides > textview:first-child {
+		border-right: 1px solid @borders;
+	}
+`)
+
+var sideTags = textutil.TextTagsMap{
+	"removed": {"paragraph-background": "rgba(224, 27, 36, 0.2)"},
+	"added":   {"paragraph-background": "rgba(38, 162, 105, 0.2)"},
+	"filler":  {"paragraph-background": "rgba(127, 127, 127, 0.1)"},
+}
+
+// ShowHistory opens a window listing the commits that changed the file. Each
+// revision can be compared against the buffer, blamed, and restored into the
+// buffer.
+func (v *View) ShowHistory() {
+	if v.path == "" {
+		return
+	}
+
+	path := v.path
+
+	gtkutil.Async(v.ctx, func() func() {
+		repo, revisions, err := fileHistory(path)
+
+		return func() {
+			switch {
+			case err != nil:
+				v.Toast.Show("Error: " + err.Error())
+			case len(revisions) == 0:
+				v.Toast.Show("This file has no history yet.")
+			case v.path == path:
+				newHistoryWindow(v, repo, revisions).Show()
+			}
+		}
+	})
+}
+
+func fileHistory(path string) (*vcs.Repository, []vcs.Revision, error) {
+	repo, err := vcs.Open(filepath.Dir(path))
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			err = errors.New("the file isn't in a git repository")
+		}
+		return nil, nil, err
+	}
+
+	revisions, err := repo.FileHistory(path)
+	return repo, revisions, err
+}
+
+type historyWindow struct {
+	*gtk.Window
+	view *View
+	repo *vcs.Repository
+	path string
+	mu   sync.Mutex // guards repo
+
+	revisions []vcs.Revision
+	selected  *vcs.Revision
+	contents  *string // of the selected revision, nil if deleted
+	loading   bool
+
+	list    *gtk.ListBox
+	pages   *gtk.Stack
+	diff    *sideBySide
+	blame   *sideBySide
+	status  *gtk.Label
+	restore *gtk.Button
+}
+
+func newHistoryWindow(v *View, repo *vcs.Repository, revisions []vcs.Revision) *historyWindow {
+	w := historyWindow{
+		view:      v,
+		repo:      repo,
+		path:      v.path,
+		revisions: revisions,
+	}
+
+	w.list = gtk.NewListBox()
+	w.list.SetSelectionMode(gtk.SelectionBrowse)
+	for _, rev := range revisions {
+		w.list.Append(newRevisionRow(rev))
+	}
+	w.list.ConnectRowSelected(func(row *gtk.ListBoxRow) {
+		if row != nil {
+			w.selectRevision(row.Index())
+		}
+	})
+
+	listScroll := gtk.NewScrolledWindow()
+	listScroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	listScroll.SetSizeRequest(220, -1)
+	listScroll.SetChild(w.list)
+
+	w.diff = newSideBySide(true)
+	w.blame = newSideBySide(false)
+	w.blame.left.AddCSSClass("dim-label")
+
+	w.status = gtk.NewLabel("")
+	w.status.AddCSSClass("dim-label")
+	w.status.SetXAlign(0)
+	w.status.SetEllipsize(pango.EllipsizeEnd)
+	w.status.SetMarginStart(6)
+	w.status.SetMarginEnd(6)
+	w.status.SetMarginTop(4)
+	w.status.SetMarginBottom(4)
+
+	w.pages = gtk.NewStack()
+	w.pages.SetVExpand(true)
+	w.pages.AddTitled(w.diff, "diff", "Changes")
+	w.pages.AddTitled(w.blame, "blame", "Blame")
+	w.pages.NotifyProperty("visible-child", w.update)
+
+	right := gtk.NewBox(gtk.OrientationVertical, 0)
+	right.Append(w.status)
+	right.Append(w.pages)
+
+	paned := gtk.NewPaned(gtk.OrientationHorizontal)
+	paned.SetStartChild(listScroll)
+	paned.SetEndChild(right)
+	paned.SetShrinkStartChild(false)
+	paned.SetResizeStartChild(false)
+
+	switcher := gtk.NewStackSwitcher()
+	switcher.SetStack(w.pages)
+
+	w.restore = gtk.NewButtonWithLabel("Restore")
+	w.restore.AddCSSClass("suggested-action")
+	w.restore.SetTooltipText("Replace the buffer with this version")
+	w.restore.ConnectClicked(w.restoreSelected)
+
+	header := gtk.NewHeaderBar()
+	header.SetTitleWidget(switcher)
+	header.PackEnd(w.restore)
+
+	w.Window = gtk.NewWindow()
+	w.Window.SetTitle("History of " + filepath.Base(w.path))
+	w.Window.SetTitlebar(header)
+	w.Window.SetDefaultSize(900, 600)
+	w.Window.SetTransientFor(&app.WindowFromContext(v.ctx).Window)
+	w.Window.SetChild(paned)
+	historyCSS(w.Window)
+
+	gtkutil.BindKeys(w.Window, map[string]func() bool{
+		"Escape": func() bool {
+			w.Window.Close()
+			return true
+		},
+	})
+
+	w.list.SelectRow(w.list.RowAtIndex(0))
+	return &w
+}
+
+func newRevisionRow(rev vcs.Revision) *gtk.ListBoxRow {
+	summary := gtk.NewLabel(rev.Summary())
+	summary.AddCSSClass("editor-history-summary")
+	summary.SetXAlign(0)
+	summary.SetEllipsize(pango.EllipsizeEnd)
+
+	info := gtk.NewLabel(fmt.Sprintf(
+		"%s · %s · %s", rev.ShortHash(), rev.Author, rev.When.Local().Format(revisionDate),
+	))
+	info.AddCSSClass("dim-label")
+	info.SetXAlign(0)
+	info.SetEllipsize(pango.EllipsizeEnd)
+
+	box := gtk.NewBox(gtk.OrientationVertical, 2)
+	box.AddCSSClass("editor-history-revision")
+	box.Append(summary)
+	box.Append(info)
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+	row.SetTooltipText(strings.TrimSpace(rev.Message))
+	return row
+}
+
+// selectRevision loads the contents of the nth revision.
+func (w *historyWindow) selectRevision(n int) {
+	rev := &w.revisions[n]
+	w.selected = rev
+	w.contents = nil
+	w.loading = true
+	w.restore.SetSensitive(false)
+	w.status.SetText("Loading...")
+
+	gtkutil.Async(w.view.ctx, func() func() {
+		w.mu.Lock()
+		contents, err := w.repo.FileAt(rev.Hash, w.path)
+		w.mu.Unlock()
+
+		return func() {
+			if w.selected != rev {
+				return
+			}
+			w.loading = false
+
+			switch {
+			case errors.Is(err, vcs.ErrNotCommitted):
+				// Deleted by this revision.
+			case err != nil:
+				w.status.SetText("Error: " + err.Error())
+				return
+			default:
+				w.contents = &contents
+			}
+
+			w.restore.SetSensitive(w.contents != nil && w.view.Source.Editable())
+			w.update()
+		}
+	})
+}
+
+// update refreshes the visible page for the selected revision.
+func (w *historyWindow) update() {
+	rev := w.selected
+	if rev == nil || w.loading {
+		return
+	}
+
+	if w.contents == nil {
+		w.status.SetText("The file was deleted in " + rev.ShortHash() + ".")
+		w.diff.clear()
+		w.blame.clear()
+		return
+	}
+
+	switch w.pages.VisibleChildName() {
+	case "diff":
+		w.status.SetText("Changes from " + rev.ShortHash() + " to the current buffer.")
+
+		old := *w.contents
+		start, end := w.view.Buffer.Bounds()
+		text := w.view.Buffer.Text(start, end, true)
+
+		gtkutil.Async(w.view.ctx, func() func() {
+			left, right := vcs.SideBySide(old, text)
+
+			return func() {
+				if w.selected == rev {
+					w.diff.set(left, right)
+				}
+			}
+		})
+
+	case "blame":
+		w.status.SetText("Last change of each line as of " + rev.ShortHash() + ".")
+
+		gtkutil.Async(w.view.ctx, func() func() {
+			w.mu.Lock()
+			lines, err := w.repo.Blame(rev.Hash, w.path)
+			w.mu.Unlock()
+
+			return func() {
+				if w.selected != rev {
+					return
+				}
+				if err != nil {
+					w.status.SetText("Error: " + err.Error())
+					w.blame.clear()
+					return
+				}
+				w.blame.set(blameSides(lines))
+			}
+		})
+	}
+}
+
+// blameSides returns the annotations and the text of the blamed lines.
+func blameSides(lines []vcs.BlameLine) (left, right []vcs.SideLine) {
+	left = make([]vcs.SideLine, len(lines))
+	right = make([]vcs.SideLine, len(lines))
+
+	for i, line := range lines {
+		// Only annotate the first of consecutive lines from the same commit.
+		if i == 0 || lines[i-1].Hash != line.Hash {
+			left[i].Text = fmt.Sprintf(
+				"%s %s %s", line.ShortHash(), line.When.Local().Format("2006-01-02"), line.Author,
+			)
+		}
+		right[i].Text = line.Text
+	}
+
+	return left, right
+}
+
+// restoreSelected replaces the buffer with the selected revision as a single
+// undoable action.
+func (w *historyWindow) restoreSelected() {
+	if w.contents == nil || w.view.path != w.path {
+		return
+	}
+
+	v := w.view
+	rev := *w.selected
+
+	v.Buffer.BeginUserAction()
+	start, end := v.Buffer.Bounds()
+	v.Buffer.Delete(start, end)
+	v.Buffer.Insert(start, *w.contents)
+	v.Buffer.EndUserAction()
+
+	v.Toast.Show(
+		"Restored the version from "+rev.When.Local().Format(revisionDate)+".",
+		gtkutil.ActionData{Name: "Undo", Func: func() {
+			v.Toast.Dismiss()
+			v.Buffer.Emit("undo")
+		}},
+	)
+
+	w.update()
+}
+
+// sideBySide shows two texts next to each other, scrolling together.
+type sideBySide struct {
+	*gtk.ScrolledWindow
+	left  *gtk.TextView
+	right *gtk.TextView
+}
+
+func newSideBySide(homogeneous bool) *sideBySide {
+	s := sideBySide{
+		left:  newSideView(),
+		right: newSideView(),
+	}
+	s.left.SetHExpand(homogeneous)
+	s.right.SetHExpand(true)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	box.AddCSSClass("editor-history-sides")
+	box.SetHomogeneous(homogeneous)
+	box.Append(s.left)
+	box.Append(s.right)
+
+	s.ScrolledWindow = gtk.NewScrolledWindow()
+	s.ScrolledWindow.SetPolicy(gtk.PolicyAutomatic, gtk.PolicyAutomatic)
+	s.ScrolledWindow.SetChild(box)
+
+	return &s
+}
+
+func newSideView() *gtk.TextView {
+	view := gtk.NewTextView()
+	view.SetEditable(false)
+	view.SetMonospace(true)
+	view.SetWrapMode(gtk.WrapNone)
+	return view
+}
+
+func (s *sideBySide) set(left, right []vcs.SideLine) {
+	setSide(s.left, left, "removed")
+	setSide(s.right, right, "added")
+}
+
+func (s *sideBySide) clear() {
+	s.set(nil, nil)
+}
+
+// setSide shows the lines in the view, highlighting changed ones with the
+// given tag.
+func setSide(view *gtk.TextView, lines []vcs.SideLine, changedTag string) {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+
+	buf := view.Buffer()
+	buf.SetText(strings.Join(texts, "\n"))
+
+	for i, line := range lines {
+		var tag string
+		switch line.Kind {
+		case vcs.SideChanged:
+			tag = changedTag
+		case vcs.SideFiller:
+			tag = "filler"
+		default:
+			continue
+		}
+
+		start, _ := buf.IterAtLine(i)
+		end := start.Copy()
+		end.ForwardLine()
+		buf.ApplyTag(sideTags.FromBuffer(buf, tag), start, end)
+	}
+}
@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/jotup/internal/jotup/editor/mdedit"
+)
+
+// maxListLines is the number of lines after the cursor that are renumbered
+// when continuing a numbered list.
+const maxListLines = 500
+
+// bindListKeys continues lists and quotes on Enter, and indents list items on
+// Tab and Shift+Tab. In tables, Tab and Shift+Tab move between cells instead.
+func (v *View) bindListKeys() {
+	keys := gtk.NewEventControllerKey()
+	keys.SetPropagationPhase(gtk.PhaseCapture)
+	keys.ConnectKeyPressed(func(val, _ uint, mods gdk.ModifierType) bool {
+		// Vim has its own keys, and other languages have their own lists.
+		if v.vimEnabled() || !v.Source.Editable() || !v.isMarkdown() {
+			return false
+		}
+
+		mods &= gtk.AcceleratorGetDefaultModMask()
+
+		switch {
+		case (val == gdk.KEY_Return || val == gdk.KEY_KP_Enter) && mods == 0:
+			return v.continueList()
+		case val == gdk.KEY_Tab && mods == 0:
+			return v.moveTableCell(false) || v.indentList(false)
+		case (val == gdk.KEY_ISO_Left_Tab || val == gdk.KEY_Tab) && mods == gdk.ShiftMask:
+			return v.moveTableCell(true) || v.indentList(true)
+		}
+
+		return false
+	})
+
+	v.Source.AddController(keys)
+}
+
+// isMarkdown returns true if the buffer is Markdown or plain text.
+func (v *View) isMarkdown() bool {
+	lang := v.Buffer.Language()
+	return lang == nil || lang.ID() == "markdown"
+}
+
+// inCodeBlock returns true if the line of the iterator is within a fenced code
+// block, where lines that look like list items or tables are code.
+func (v *View) inCodeBlock(iter *gtk.TextIter) bool {
+	start := iter.Copy()
+	start.SetLineOffset(0)
+	return mdedit.InCodeBlock(v.Buffer.Text(v.Buffer.StartIter(), start, true))
+}
+
+// continueList breaks the line at the cursor, continuing its list item or
+// quote. False is returned if there's none, or if it's in a code block.
+func (v *View) continueList() bool {
+	if v.Buffer.HasSelection() {
+		return false
+	}
+
+	cursor := v.Buffer.IterAtMark(v.Buffer.GetInsert())
+
+	start := cursor.Copy()
+	start.SetLineOffset(0)
+
+	if v.inCodeBlock(start) {
+		return false
+	}
+
+	// Take the rest of the list up to the next blank line, which has any
+	// numbers that need to be renumbered.
+	end := start.Copy()
+	for i := 0; i < maxListLines; i++ {
+		if !end.ForwardLine() || end.EndsLine() {
+			break
+		}
+	}
+
+	base := start.Offset()
+	in := mdedit.Block{
+		Text:     v.Buffer.Text(start, end, true),
+		SelStart: cursor.Offset() - base,
+		SelEnd:   cursor.Offset() - base,
+	}
+
+	out, ok := mdedit.ContinueList(in, tabWidth.Value())
+	if !ok {
+		return false
+	}
+
+	v.applyBlock(base, in, out)
+	return true
+}
+
+// indentList indents or outdents the selected list items by one level,
+// following the tab preferences. False is returned if not all selected lines
+// are list items, or if they're in a code block.
+func (v *View) indentList(outdent bool) bool {
+	unit := "\t"
+	if insertSpaces.Value() {
+		unit = strings.Repeat(" ", tabWidth.Value())
+	}
+
+	base, in := v.selectedLines()
+	if v.inCodeBlock(v.Buffer.IterAtOffset(base)) {
+		return false
+	}
+
+	out, ok := mdedit.IndentList(in, unit, tabWidth.Value(), outdent)
+	if !ok {
+		return false
+	}
+
+	v.applyBlock(base, in, out)
+	return true
+}
@@ -0,0 +1,54 @@
+// Package chromastyle finds chroma styles by name, including the user's own
+// JSON styles. It does not require GTK, so it can be used headlessly.
+package chromastyle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/pkg/errors"
+)
+
+// Styles used if the user hasn't set a style in the config.
+const (
+	DefaultDarkStyle  = "monokai"
+	DefaultLightStyle = "solarized-light"
+)
+
+// Find finds the chroma style with the given name. Styles bundled with chroma
+// are tried first, then the JSON style named name+".json" in dir. If dir is
+// empty, then the fallback style is returned for unknown names.
+func Find(dir, name string) (*chroma.Style, error) {
+	s := styles.Get(name)
+	if s != styles.Fallback {
+		return s, nil
+	}
+
+	if name == "" || dir == "" {
+		return styles.Fallback, nil
+	}
+
+	d, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("unknown style %s", name)
+		}
+		return nil, err
+	}
+
+	var entries chroma.StyleEntries
+	if err := json.Unmarshal(d, &entries); err != nil {
+		return nil, errors.Wrap(err, "failed to parse JSON chroma styles")
+	}
+
+	s, err = chroma.NewStyle(name, entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse actual styling")
+	}
+
+	return s, nil
+}
@@ -2,10 +2,7 @@ package hl
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
-	"os"
 	"strings"
 	"sync"
 	"unicode/utf8"
@@ -18,7 +15,7 @@ import (
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/app/prefs"
 	"github.com/diamondburned/gotkit/gtkutil/textutil"
-	"github.com/pkg/errors"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/chromastyle"
 )
 
 // var stylePath = config.Path("styles")
@@ -33,8 +30,8 @@ var Style = prefs.NewString("", prefs.StringMeta{
 
 // Styles used if the user hasn't set a style in the config.
 const (
-	DefaultDarkStyle  = "monokai"
-	DefaultLightStyle = "solarized-light"
+	DefaultDarkStyle  = chromastyle.DefaultDarkStyle
+	DefaultLightStyle = chromastyle.DefaultLightStyle
 )
 
 // tagMap is a map from chroma token types to text tag attributes.
@@ -89,39 +86,11 @@ func mustStyle(ctx context.Context, theme string) *chroma.Style {
 }
 
 func findStyle(ctx context.Context, theme string) (*chroma.Style, error) {
-	s := styles.Get(theme)
-	if s != styles.Fallback {
-		return s, nil
+	var dir string
+	if app := app.FromContext(ctx); app != nil {
+		dir = app.ConfigPath("styles")
 	}
-
-	if theme == "" {
-		return styles.Fallback, nil
-	}
-
-	app := app.FromContext(ctx)
-	if app == nil {
-		return styles.Fallback, nil
-	}
-
-	d, err := os.ReadFile(app.ConfigPath("styles", theme+".json"))
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("unknown style %s", theme)
-		}
-		return nil, err
-	}
-
-	var styles chroma.StyleEntries
-	if err := json.Unmarshal(d, &styles); err != nil {
-		return nil, errors.Wrap(err, "failed to parse JSON chroma styles")
-	}
-
-	s, err = chroma.NewStyle(theme, styles)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse actual styling")
-	}
-
-	return s, nil
+	return chromastyle.Find(dir, theme)
 }
 
 func convertStyle(style *chroma.Style) tagMap {
@@ -0,0 +1,178 @@
+// Package mathext implements a goldmark extension that parses TeX math: $...$
+// for inline math and $$...$$ for display (block) math.
+package mathext
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindInlineMath is the NodeKind for InlineMath.
+var KindInlineMath = ast.NewNodeKind("InlineMath")
+
+// KindBlockMath is the NodeKind for BlockMath.
+var KindBlockMath = ast.NewNodeKind("BlockMath")
+
+// InlineMath is an inline node containing TeX math, such as $x^2$.
+type InlineMath struct {
+	ast.BaseInline
+	// Segment is the segment of the TeX source without the dollar signs.
+	Segment text.Segment
+}
+
+// Kind implements ast.Node.
+func (n *InlineMath) Kind() ast.NodeKind { return KindInlineMath }
+
+// IsRaw implements ast.Node.
+func (n *InlineMath) IsRaw() bool { return true }
+
+// Dump implements ast.Node.
+func (n *InlineMath) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"TeX": string(n.TeX(source)),
+	}, nil)
+}
+
+// TeX returns the TeX source of the node.
+func (n *InlineMath) TeX(source []byte) []byte {
+	return n.Segment.Value(source)
+}
+
+// BlockMath is a block node containing display TeX math delimited by $$.
+type BlockMath struct {
+	ast.BaseBlock
+	closed bool
+}
+
+// Kind implements ast.Node.
+func (n *BlockMath) Kind() ast.NodeKind { return KindBlockMath }
+
+// IsRaw implements ast.Node.
+func (n *BlockMath) IsRaw() bool { return true }
+
+// Dump implements ast.Node.
+func (n *BlockMath) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"TeX": string(n.TeX(source)),
+	}, nil)
+}
+
+// TeX returns the TeX source of the node with surrounding whitespaces trimmed.
+func (n *BlockMath) TeX(source []byte) []byte {
+	var buf bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+	return bytes.TrimSpace(buf.Bytes())
+}
+
+// Extension is the goldmark extension. Use it with goldmark.WithExtensions.
+var Extension goldmark.Extender = extension{}
+
+type extension struct{}
+
+func (extension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(blockParser{}, 750)),
+		parser.WithInlineParsers(util.Prioritized(inlineParser{}, 150)),
+	)
+}
+
+type inlineParser struct{}
+
+var dollar = []byte{'$'}
+
+func (inlineParser) Trigger() []byte { return dollar }
+
+func (inlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, seg := block.PeekLine()
+	if len(line) < 3 || line[0] != '$' || line[1] == '$' || line[1] == ' ' {
+		return nil
+	}
+
+	for i := 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '$':
+			if line[i-1] == ' ' {
+				return nil
+			}
+			block.Advance(i + 1)
+			return &InlineMath{Segment: text.NewSegment(seg.Start+1, seg.Start+i)}
+		}
+	}
+
+	return nil
+}
+
+type blockParser struct{}
+
+var blockDelim = []byte("$$")
+
+func (blockParser) Trigger() []byte { return dollar }
+
+func (blockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, seg := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], blockDelim) {
+		return nil, parser.NoChildren
+	}
+
+	node := &BlockMath{}
+	start := seg.Start + pos + len(blockDelim)
+	rest := bytes.TrimRight(line[pos+len(blockDelim):], "\r\n")
+
+	// Handle the single-line $$ x $$ case.
+	if end := bytes.Index(rest, blockDelim); end > -1 {
+		node.Lines().Append(text.NewSegment(start, start+end))
+		node.closed = true
+		reader.Advance(len(line) - 1)
+		return node, parser.NoChildren
+	}
+
+	if len(bytes.TrimSpace(rest)) > 0 {
+		node.Lines().Append(text.NewSegment(start, seg.Stop))
+	}
+
+	reader.Advance(len(line) - 1)
+	return node, parser.NoChildren
+}
+
+func (blockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	math := node.(*BlockMath)
+	if math.closed {
+		return parser.Close
+	}
+
+	line, seg := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	if end := bytes.Index(line, blockDelim); end > -1 {
+		if end > 0 {
+			math.Lines().Append(text.NewSegment(seg.Start, seg.Start+end))
+		}
+		math.closed = true
+		reader.Advance(len(line) - 1)
+		return parser.Close
+	}
+
+	math.Lines().Append(seg)
+	reader.Advance(len(line) - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (blockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (blockParser) CanInterruptParagraph() bool { return true }
+
+func (blockParser) CanAcceptIndentedLine() bool { return false }
@@ -0,0 +1,67 @@
+package mathext
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// math is a parsed math node: "inline" or "block" and its TeX.
+type math struct {
+	kind string
+	tex  string
+}
+
+func parseMath(src string) []math {
+	md := goldmark.New(goldmark.WithExtensions(Extension))
+	doc := md.Parser().Parse(text.NewReader([]byte(src)))
+
+	var found []math
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *InlineMath:
+			found = append(found, math{"inline", string(n.TeX([]byte(src)))})
+		case *BlockMath:
+			found = append(found, math{"block", string(n.TeX([]byte(src)))})
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []math
+	}{
+		{"inline", "a $x^2$ b", []math{{"inline", "x^2"}}},
+		{"two inline", "$a$ and $b$", []math{{"inline", "a"}, {"inline", "b"}}},
+		{"escaped dollar", `$a \$ b$`, []math{{"inline", `a \$ b`}}},
+		{"space after opening", "costs $ 5 and $6", nil},
+		{"space before closing", "$5 and 6 $ more", nil},
+		{"unclosed inline", "only $x here", nil},
+		{"dollar in code span", "`$x$` and $y$", []math{{"inline", "y"}}},
+		{"dollar in fenced code", "```\n$x$\n$$\ny\n$$\n```", nil},
+		{"block", "$$\nx + y\n$$", []math{{"block", "x + y"}}},
+		{"one-line block", "$$ x + y $$", []math{{"block", "x + y"}}},
+		{"block with content on delimiters", "$$ a\nb $$", []math{{"block", "a\nb"}}},
+		{"block after paragraph", "text\n$$\nx\n$$\nmore", []math{{"block", "x"}}},
+		{"unclosed block runs to the end", "$$\nx\n\ny", []math{{"block", "x\n\ny"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseMath(test.src)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("math in %q = %v, want %v", test.src, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package mdedit
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// itemRe matches the start of a list item or a quoted line: the quote markers,
+// the indentation, the list marker with its spacing and a task box.
+var itemRe = regexp.MustCompile(
+	`^((?:[ \t]*>[ ]?)*)([ \t]*)(?:(?:([-*+])|(\d{1,9})([.)]))([ \t]+)(\[[ xX]\][ \t]+)?)?`,
+)
+
+// item is the parsed start of a list item or a quoted line.
+type item struct {
+	quote  string // such as "> > "
+	indent string
+	bullet string // "-", "*" or "+"
+	number int    // 0 if not numbered
+	digits int    // the length of the number as written
+	delim  string // "." or ")" after the number
+	space  string
+	task   bool
+	end    int // the length of the whole prefix
+}
+
+func (it item) isList() bool { return it.bullet != "" || it.number > 0 }
+
+// marker returns the list marker of the item with the given number, such as
+// "- [ ] " or "3. ". Quoted lines have none.
+func (it item) marker(number int) string {
+	var marker string
+	switch {
+	case !it.isList():
+		return ""
+	case it.bullet != "":
+		marker = it.bullet + it.space
+	default:
+		marker = strconv.Itoa(number) + it.delim + it.space
+	}
+	if it.task {
+		marker += "[ ] "
+	}
+	return marker
+}
+
+// parseItem parses the line as a list item or a quoted line.
+func parseItem(line string) (item, bool) {
+	m := itemRe.FindStringSubmatch(line)
+	if m == nil {
+		return item{}, false
+	}
+
+	it := item{
+		quote:  m[1],
+		indent: m[2],
+		bullet: m[3],
+		delim:  m[5],
+		space:  m[6],
+		task:   m[7] != "",
+		end:    len(m[0]),
+	}
+	if m[4] != "" {
+		it.number, _ = strconv.Atoi(m[4])
+		it.digits = len(m[4])
+	}
+
+	if !it.isList() {
+		if it.quote == "" {
+			return item{}, false
+		}
+		// Indentation in a quote is its content.
+		it.indent = ""
+		it.end = len(it.quote)
+	}
+
+	return it, true
+}
+
+// ContinueList breaks the first line of the text at the cursor like Enter
+// would, continuing its list item or quote on the new line. The text holds the
+// lines of the rest of the list so that numbered items after the cursor can be
+// renumbered. On an empty item, a nested item is outdented by one level of the
+// given width like IndentList does, and the marker of any other item is
+// removed to end the list, keeping its quote. False is returned if the line
+// isn't a list item or a quote, or if the cursor is within the marker.
+func ContinueList(b Block, width int) (Block, bool) {
+	cursor, _ := b.selection()
+
+	lineEnd := strings.IndexByte(b.Text, '\n')
+	if lineEnd < 0 {
+		lineEnd = len(b.Text)
+	}
+	line := b.Text[:lineEnd]
+
+	it, ok := parseItem(line)
+	if !ok || cursor < it.end || cursor > lineEnd {
+		return b, false
+	}
+
+	if strings.TrimSpace(line[it.end:]) == "" {
+		var prefix string
+		if it.isList() {
+			prefix = it.quote
+			if it.indent != "" {
+				// Outdent the nested item into its parent list.
+				marker := line[len(it.quote)+len(it.indent) : it.end]
+				prefix += it.indent[outdentWidth(it.indent, width):] + marker
+			}
+		}
+
+		text := b.Text[lineEnd:]
+		if it.number > 0 {
+			text = renumber(text, it, it.number)
+		}
+		return withSelection(prefix+text, len(prefix), len(prefix)), true
+	}
+
+	next := it.quote + it.indent + it.marker(it.number+1)
+	rest := strings.TrimLeft(b.Text[cursor:lineEnd], " \t") + b.Text[lineEnd:]
+	if it.number > 0 {
+		rest = renumberAfter(rest, it, it.number+2)
+	}
+
+	text := b.Text[:cursor] + "\n" + next + rest
+	cursor += len("\n") + len(next)
+	return withSelection(text, cursor, cursor), true
+}
+
+// renumberAfter renumbers the items following the first line of the text.
+func renumberAfter(text string, it item, from int) string {
+	i := strings.IndexByte(text, '\n')
+	if i < 0 {
+		return text
+	}
+	return text[:i] + renumber(text[i:], it, from)
+}
+
+// renumber renumbers the items of the same list as it from the given number,
+// stopping where the list ends. The text starts with a new line.
+func renumber(text string, it item, from int) string {
+	var out strings.Builder
+	out.Grow(len(text))
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		if i == 0 || from < 0 {
+			out.WriteString(line)
+			continue
+		}
+
+		other, ok := parseItem(line)
+		if !ok {
+			other = item{}
+		}
+		content := line[len(other.quote):]
+		indent := len(content) - len(strings.TrimLeft(content, " \t"))
+
+		switch {
+		case strings.TrimSpace(line) == "" || other.quote != it.quote:
+			// The list ended.
+			from = -1
+		case other.isList() && other.indent == it.indent:
+			if other.number == 0 {
+				// A bulleted list follows.
+				from = -1
+				break
+			}
+			prefix := len(other.quote) + len(other.indent)
+			out.WriteString(line[:prefix])
+			out.WriteString(strconv.Itoa(from))
+			out.WriteString(line[prefix+other.digits:])
+			from++
+			continue
+		case indent <= len(it.indent):
+			// Neither a nested item nor a continued paragraph of an item.
+			from = -1
+		}
+
+		out.WriteString(line)
+	}
+
+	return out.String()
+}
+
+// IndentList indents the list items of the lines by one level, or outdents
+// them. unit is the indentation of one level, and width is the number of spaces
+// that a tab counts for when outdenting. False is returned if any of the lines
+// isn't a list item.
+func IndentList(b Block, unit string, width int, outdent bool) (Block, bool) {
+	lines := nonEmptyLines(b.Text)
+	if len(lines) == 0 {
+		return b, false
+	}
+	for _, line := range lines {
+		if it, ok := parseItem(line); !ok || !it.isList() {
+			return b, false
+		}
+	}
+
+	return formatLines(b, func(line string) (linePrefix, bool) {
+		it, ok := parseItem(line)
+		if !ok || !it.isList() {
+			return linePrefix{}, false
+		}
+
+		start := len(it.quote)
+		if !outdent {
+			return linePrefix{start: start, end: start, text: unit}, true
+		}
+
+		return linePrefix{start: start, end: start + outdentWidth(it.indent, width)}, true
+	}), true
+}
+
+// outdentWidth returns how many bytes of the indentation make up one level of
+// the given width: a tab, or up to width spaces.
+func outdentWidth(indent string, width int) int {
+	if strings.HasPrefix(indent, "\t") {
+		return 1
+	}
+
+	n := 0
+	for n < len(indent) && n < width && indent[n] == ' ' {
+		n++
+	}
+	return n
+}
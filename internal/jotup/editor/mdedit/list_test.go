@@ -0,0 +1,121 @@
+package mdedit
+
+import "testing"
+
+func TestContinueList(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string // empty if the list isn't continued
+	}{
+		{"bullet", "- a‸", "- a\n- ‸"},
+		{"split item", "- a‸b", "- a\n- ‸b"},
+		{"task", "- [x] a‸", "- [x] a\n- [ ] ‸"},
+		{"numbered", "1. a‸", "1. a\n2. ‸"},
+		{"paren", "9) a‸", "9) a\n10) ‸"},
+		{"renumber", "1. a‸\n2. b\n3. c", "1. a\n2. ‸\n3. b\n4. c"},
+		{"nested", "- a\n  - b‸", ""},
+		{"indented", "  - b‸", "  - b\n  - ‸"},
+		{"quote", "> a‸", "> a\n> ‸"},
+		{"quoted item", "> - a‸", "> - a\n> - ‸"},
+		{"end list", "- ‸", "‸"},
+		{"end numbered list", "2. ‸\n3. b", "‸\n2. b"},
+		{"end quote", "> ‸", "‸"},
+		{"end quoted list", "> - ‸", "> ‸"},
+		{"outdent nested", "  - ‸", "- ‸"},
+		{"outdent tab", "\t\t- ‸", "\t- ‸"},
+		{"outdent quoted", ">     1. ‸\n>     2. b", "> 1. ‸\n>     1. b"},
+		{"not a list", "a‸", ""},
+		{"within marker", "-‸ a", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, ok := ContinueList(parseBlock(t, test.in), 4)
+			if !ok {
+				if test.out != "" {
+					t.Fatal("list not continued")
+				}
+				return
+			}
+			if test.out == "" {
+				t.Fatalf("list continued as %q", formatBlock(out))
+			}
+			if got := formatBlock(out); got != test.out {
+				t.Errorf("expected %q, got %q", test.out, got)
+			}
+		})
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	list := item{indent: "", number: 1, delim: "."}
+
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{"items", "\n1. a\n1. b", "\n5. a\n6. b"},
+		{"keeps delimiter", "\n1) a", "\n5) a"},
+		{"more digits", "\n9. a\n10. b", "\n5. a\n6. b"},
+		{"skips nested", "\n1. a\n   1. x\n   paragraph\n2. b", "\n5. a\n   1. x\n   paragraph\n6. b"},
+		{"stops at blank line", "\n1. a\n\n2. b", "\n5. a\n\n2. b"},
+		{"stops at bullets", "\n1. a\n- b\n3. c", "\n5. a\n- b\n3. c"},
+		{"stops at paragraph", "\n1. a\ntext\n3. c", "\n5. a\ntext\n3. c"},
+		{"stops at quote", "\n1. a\n> 2. b", "\n5. a\n> 2. b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if out := renumber(test.in, list, 5); out != test.out {
+				t.Errorf("expected %q, got %q", test.out, out)
+			}
+		})
+	}
+
+	// The first line isn't part of the renumbered items.
+	if out := renumberAfter("1. a\n1. b", list, 5); out != "1. a\n5. b" {
+		t.Errorf("renumberAfter: expected %q, got %q", "1. a\n5. b", out)
+	}
+}
+
+func TestIndentList(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		unit    string
+		outdent bool
+		out     string // empty if not indented
+	}{
+		{"indent", "- a‸", "  ", false, "  - a‸"},
+		{"indent tab", "- a‸", "\t", false, "\t- a‸"},
+		{"indent lines", "‹- a\n\n1. b›", "  ", false, "  ‹- a\n\n  1. b›"},
+		{"indent quoted", "> - a‸", "  ", false, ">   - a‸"},
+		{"outdent", "    - a‸", "", true, "- a‸"},
+		{"outdent one level", "        - a‸", "", true, "    - a‸"},
+		{"outdent tab", "\t\t- a‸", "", true, "\t- a‸"},
+		{"outdent less", "  - a‸", "", true, "- a‸"},
+		{"outdent top level", "- a‸", "", true, "- a‸"},
+		{"not all items", "‹- a\nb›", "  ", false, ""},
+		{"quote isn't an item", "> a‸", "  ", false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, ok := IndentList(parseBlock(t, test.in), test.unit, 4, test.outdent)
+			if !ok {
+				if test.out != "" {
+					t.Fatal("list not indented")
+				}
+				return
+			}
+			if test.out == "" {
+				t.Fatalf("list indented as %q", formatBlock(out))
+			}
+			if got := formatBlock(out); got != test.out {
+				t.Errorf("expected %q, got %q", test.out, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,585 @@
+// Package mdedit implements Markdown editing commands on plain text, so that
+// they can be used on any text buffer.
+package mdedit
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Block is the text of whole lines with a selection in it. Offsets count
+// characters rather than bytes, like GTK text iterators do.
+type Block struct {
+	Text     string
+	SelStart int
+	SelEnd   int
+}
+
+// Format formats the block, returning the changed block with the selection
+// moved along.
+type Format func(Block) Block
+
+// Formats are the formatting commands by name.
+var Formats = map[string]Format{
+	"bold":          Bold,
+	"italic":        Italic,
+	"code":          Code,
+	"link":          Link,
+	"heading-1":     Heading(1),
+	"heading-2":     Heading(2),
+	"heading-3":     Heading(3),
+	"heading-4":     Heading(4),
+	"heading-5":     Heading(5),
+	"heading-6":     Heading(6),
+	"quote":         Quote,
+	"bulleted-list": BulletedList,
+	"numbered-list": NumberedList,
+}
+
+var (
+	// Bold toggles **bold** text.
+	Bold = inline("**")
+	// Italic toggles _italic_ text.
+	Italic = inline("_")
+	// Code toggles `code` spans.
+	Code = inline("`")
+)
+
+// byteOffset returns the byte offset of the character offset in s, clamped to
+// its length.
+func byteOffset(s string, chars int) int {
+	for i := range s {
+		if chars <= 0 {
+			return i
+		}
+		chars--
+	}
+	return len(s)
+}
+
+// charOffset returns the character offset of the byte offset in s.
+func charOffset(s string, bytes int) int {
+	return utf8.RuneCountInString(s[:bytes])
+}
+
+// selection returns the selection of the block as sorted byte offsets.
+func (b Block) selection() (int, int) {
+	start := byteOffset(b.Text, b.SelStart)
+	end := byteOffset(b.Text, b.SelEnd)
+	if start > end {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// withSelection returns the block of the text with the selection at the given
+// byte offsets.
+func withSelection(text string, start, end int) Block {
+	return Block{
+		Text:     text,
+		SelStart: charOffset(text, start),
+		SelEnd:   charOffset(text, end),
+	}
+}
+
+// lineSpan is a line in a text, in byte offsets excluding the new line.
+type lineSpan struct{ start, end int }
+
+func splitLines(text string) []lineSpan {
+	var lines []lineSpan
+	start := 0
+	for {
+		i := strings.IndexByte(text[start:], '\n')
+		if i < 0 {
+			return append(lines, lineSpan{start, len(text)})
+		}
+		lines = append(lines, lineSpan{start, start + i})
+		start += i + 1
+	}
+}
+
+// inline returns a Format that wraps the selection in the marker, or unwraps
+// it if it's already wrapped. Selections across lines wrap each line, since
+// Markdown spans can't cross paragraphs.
+func inline(marker string) Format {
+	return func(b Block) Block {
+		start, end := b.selection()
+		if start == end {
+			return inlineAtCursor(marker, b.Text, start)
+		}
+
+		// Find the selected part of each line without the surrounding spaces,
+		// which would stop the markers from applying.
+		type segment struct {
+			line lineSpan
+			a, b int // relative to the line
+		}
+		var segments []segment
+
+		for _, line := range splitLines(b.Text) {
+			a, z := max(start, line.start), min(end, line.end)
+			if a >= z {
+				continue
+			}
+
+			text := b.Text[line.start:line.end]
+			a, z = a-line.start, z-line.start
+			for a < z && isSpace(text[a]) {
+				a++
+			}
+			for z > a && isSpace(text[z-1]) {
+				z--
+			}
+			if a < z {
+				segments = append(segments, segment{line, a, z})
+			}
+		}
+
+		if len(segments) == 0 {
+			return b
+		}
+
+		unwrap := true
+		for _, seg := range segments {
+			if wrapping(b.Text[seg.line.start:seg.line.end], seg.a, seg.b, marker) == notWrapped {
+				unwrap = false
+				break
+			}
+		}
+
+		var out strings.Builder
+		last := 0
+		selStart, selEnd := -1, -1
+
+		for _, seg := range segments {
+			line := b.Text[seg.line.start:seg.line.end]
+			out.WriteString(b.Text[last:seg.line.start])
+			lineStart := out.Len()
+
+			var a, z int
+			kind := wrapping(line, seg.a, seg.b, marker)
+			switch {
+			case unwrap && kind == wrappedInside:
+				line, a, z = unwrapInside(line, seg.a, seg.b, marker)
+			case unwrap && kind == wrappedAround:
+				line, a, z = unwrapAround(line, seg.a, seg.b, marker)
+			case !unwrap && kind == notWrapped:
+				line, a, z = wrap(line, seg.a, seg.b, marker)
+			default:
+				// Already wrapped while wrapping others.
+				a, z = seg.a, seg.b
+			}
+
+			out.WriteString(line)
+			last = seg.line.end
+
+			if selStart < 0 {
+				selStart = lineStart + a
+			}
+			selEnd = lineStart + z
+		}
+		out.WriteString(b.Text[last:])
+
+		return withSelection(out.String(), selStart, selEnd)
+	}
+}
+
+// inlineAtCursor toggles the marker around the word at the cursor, or inserts
+// an empty pair if there's no word.
+func inlineAtCursor(marker, text string, cursor int) Block {
+	// Remove an empty pair, such as one that was just inserted. Pairs are
+	// only inserted outside of words, unlike the start of __init__.
+	if isEmptyPair(text, cursor, marker) {
+		text = text[:cursor-len(marker)] + text[cursor+len(marker):]
+		cursor -= len(marker)
+		return withSelection(text, cursor, cursor)
+	}
+
+	a, z := wordAt(text, cursor)
+	if a == z {
+		text = text[:cursor] + marker + marker + text[cursor:]
+		cursor += len(marker)
+		return withSelection(text, cursor, cursor)
+	}
+
+	var na, nz int
+	switch wrapping(text, a, z, marker) {
+	case wrappedInside:
+		text, na, nz = unwrapInside(text, a, z, marker)
+		cursor -= len(marker)
+	case wrappedAround:
+		text, na, nz = unwrapAround(text, a, z, marker)
+		cursor -= len(marker)
+	default:
+		text, na, nz = wrap(text, a, z, marker)
+		cursor += len(marker)
+	}
+
+	// Keep the cursor at the same place in the word.
+	cursor = clamp(cursor, na, nz)
+	return withSelection(text, cursor, cursor)
+}
+
+// isEmptyPair returns true if the cursor is within a pair of markers with
+// nothing in between and no word around.
+func isEmptyPair(text string, cursor int, marker string) bool {
+	start := cursor - len(marker)
+	end := cursor + len(marker)
+	if !isMarkerAt(text, start, marker+marker) {
+		return false
+	}
+
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !isWordRune(before) && !isWordRune(after)
+}
+
+// wordAt returns the word around the cursor, including any markers.
+func wordAt(text string, cursor int) (int, int) {
+	a := cursor
+	for a > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:a])
+		if !isWordRune(r) {
+			break
+		}
+		a -= size
+	}
+
+	z := cursor
+	for z < len(text) {
+		r, size := utf8.DecodeRuneInString(text[z:])
+		if !isWordRune(r) {
+			break
+		}
+		z += size
+	}
+
+	return a, z
+}
+
+func isWordRune(r rune) bool {
+	switch r {
+	case '*', '_', '`', '\'', '-':
+		return true
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+type wrapKind uint8
+
+const (
+	notWrapped    wrapKind = iota
+	wrappedInside          // **text** is selected
+	wrappedAround          // text is selected within **text**
+)
+
+func wrapping(line string, a, z int, marker string) wrapKind {
+	n := len(marker)
+	if z-a >= 2*n && isMarkerAt(line, a, marker) && isMarkerAt(line, z-n, marker) {
+		return wrappedInside
+	}
+	if isMarkerAt(line, a-n, marker) && isMarkerAt(line, z, marker) {
+		return wrappedAround
+	}
+	return notWrapped
+}
+
+// isMarkerAt returns true if the marker is at the byte offset of the line and
+// isn't part of a longer run of the same character, such as the _ in __init__
+// or in __bold__. Markers are runs of a single character.
+func isMarkerAt(line string, i int, marker string) bool {
+	end := i + len(marker)
+	if i < 0 || end > len(line) || line[i:end] != marker {
+		return false
+	}
+
+	c := marker[0]
+	return (i == 0 || line[i-1] != c) && (end == len(line) || line[end] != c)
+}
+
+// wrap, unwrapInside and unwrapAround return the changed line and the new
+// range of the text within the markers.
+
+func wrap(line string, a, z int, marker string) (string, int, int) {
+	line = line[:a] + marker + line[a:z] + marker + line[z:]
+	return line, a + len(marker), z + len(marker)
+}
+
+func unwrapInside(line string, a, z int, marker string) (string, int, int) {
+	line = line[:a] + line[a+len(marker):z-len(marker)] + line[z:]
+	return line, a, z - 2*len(marker)
+}
+
+func unwrapAround(line string, a, z int, marker string) (string, int, int) {
+	line = line[:a-len(marker)] + line[a:z] + line[z+len(marker):]
+	return line, a - len(marker), z - len(marker)
+}
+
+var (
+	linkRe    = regexp.MustCompile(`\[([^\]]*)\]\(([^)]*)\)`)
+	linkEndRe = regexp.MustCompile(`^\]\([^)]*\)`)
+	urlRe     = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*://\S+$`)
+)
+
+// Link turns the selection into a link with the cursor placed where the URL
+// goes, or turns a link back into its text.
+func Link(b Block) Block {
+	start, end := b.selection()
+	text := b.Text
+
+	if start == end {
+		// Unlink the link under the cursor.
+		for _, m := range linkRe.FindAllStringSubmatchIndex(text, -1) {
+			if m[0] <= start && start <= m[1] {
+				text = text[:m[0]] + text[m[2]:m[3]] + text[m[1]:]
+				cursor := m[0] + (m[3] - m[2])
+				return withSelection(text, cursor, cursor)
+			}
+		}
+
+		text = text[:start] + "[]()" + text[start:]
+		return withSelection(text, start+1, start+1)
+	}
+
+	sel := text[start:end]
+
+	if m := linkRe.FindStringSubmatchIndex(sel); m != nil && m[0] == 0 && m[1] == len(sel) {
+		label := sel[m[2]:m[3]]
+		text = text[:start] + label + text[end:]
+		return withSelection(text, start, start+len(label))
+	}
+
+	if strings.HasSuffix(text[:start], "[") {
+		if m := linkEndRe.FindStringIndex(text[end:]); m != nil {
+			text = text[:start-1] + sel + text[end+m[1]:]
+			return withSelection(text, start-1, end-1)
+		}
+	}
+
+	if urlRe.MatchString(sel) {
+		// The URL is known, so the label is what's left to type.
+		text = text[:start] + "[](" + sel + ")" + text[end:]
+		return withSelection(text, start+1, start+1)
+	}
+
+	text = text[:start] + "[" + sel + "]()" + text[end:]
+	cursor := end + len("[](")
+	return withSelection(text, cursor, cursor)
+}
+
+// linePrefix is a change to the start of a line, replacing the bytes from
+// start to end with text.
+type linePrefix struct {
+	start, end int
+	text       string
+}
+
+// formatLines applies the prefix changes returned by f to every line, keeping
+// the selection on the same text. f returns false to leave a line as is.
+func formatLines(b Block, f func(line string) (linePrefix, bool)) Block {
+	var changes []linePrefix // relative to the text
+	for _, line := range splitLines(b.Text) {
+		if p, ok := f(b.Text[line.start:line.end]); ok {
+			p.start += line.start
+			p.end += line.start
+			changes = append(changes, p)
+		}
+	}
+
+	var out strings.Builder
+	out.Grow(len(b.Text) + 2*len(changes))
+
+	last := 0
+	for _, p := range changes {
+		out.WriteString(b.Text[last:p.start])
+		out.WriteString(p.text)
+		last = p.end
+	}
+	out.WriteString(b.Text[last:])
+
+	start, end := b.selection()
+	return withSelection(out.String(), moveOffset(changes, start), moveOffset(changes, end))
+}
+
+// moveOffset returns where the offset ends up after the changes. Offsets
+// within a replaced prefix are moved after it.
+func moveOffset(changes []linePrefix, offset int) int {
+	moved := offset
+	for _, p := range changes {
+		switch {
+		case offset < p.start:
+			return moved
+		case offset >= p.end:
+			moved += len(p.text) - (p.end - p.start)
+		default:
+			return moved - (offset - p.start) + len(p.text)
+		}
+	}
+	return moved
+}
+
+// nonEmptyLines returns the lines of the text that aren't blank.
+func nonEmptyLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// all returns true if the text has lines and they all match the regex.
+func all(text string, re *regexp.Regexp) bool {
+	lines := nonEmptyLines(text)
+	for _, line := range lines {
+		if !re.MatchString(line) {
+			return false
+		}
+	}
+	return len(lines) > 0
+}
+
+var headingRe = regexp.MustCompile(`^\s*(#{1,6})(?:[ \t]+|$)`)
+
+// Heading returns a Format that turns the lines into headings of the given
+// level, or back into paragraphs if they already are.
+func Heading(level int) Format {
+	marker := strings.Repeat("#", level)
+	sameLevel := regexp.MustCompile(`^\s*` + marker + `(?:[ \t]+|$)`)
+
+	return func(b Block) Block {
+		unset := all(b.Text, sameLevel)
+
+		return formatLines(b, func(line string) (linePrefix, bool) {
+			if strings.TrimSpace(line) == "" {
+				return linePrefix{}, false
+			}
+
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			p := linePrefix{start: indent, end: indent}
+			if m := headingRe.FindStringIndex(line); m != nil {
+				p.end = m[1]
+			}
+
+			if !unset {
+				p.text = marker + " "
+			}
+			return p, true
+		})
+	}
+}
+
+var quoteRe = regexp.MustCompile(`^\s*>[ ]?`)
+
+// Quote turns the lines into a block quote, or back if they already are.
+func Quote(b Block) Block {
+	unset := all(b.Text, quoteRe)
+
+	return formatLines(b, func(line string) (linePrefix, bool) {
+		if unset {
+			m := quoteRe.FindStringIndex(line)
+			if m == nil {
+				return linePrefix{}, false
+			}
+			indent := strings.IndexByte(line, '>')
+			return linePrefix{start: indent, end: m[1]}, true
+		}
+
+		// Blank lines are quoted too, so that the quote isn't split.
+		if strings.TrimSpace(line) == "" {
+			return linePrefix{text: ">"}, true
+		}
+		return linePrefix{text: "> "}, true
+	})
+}
+
+var (
+	bulletRe = regexp.MustCompile(`^(\s*)[-*+](?:[ \t]+|$)`)
+	numberRe = regexp.MustCompile(`^(\s*)\d{1,9}[.)](?:[ \t]+|$)`)
+)
+
+// listMarker returns the indentation and the range of the list marker of the
+// line, if any.
+func listMarker(line string) (indent string, p linePrefix) {
+	for _, re := range []*regexp.Regexp{bulletRe, numberRe} {
+		if m := re.FindStringSubmatchIndex(line); m != nil {
+			return line[:m[3]], linePrefix{start: m[3], end: m[1]}
+		}
+	}
+
+	indent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	return indent, linePrefix{start: len(indent), end: len(indent)}
+}
+
+// BulletedList turns the lines into a bulleted list, or back into paragraphs
+// if they already are. Numbered items are turned into bulleted ones.
+func BulletedList(b Block) Block {
+	unset := all(b.Text, bulletRe)
+
+	return formatLines(b, func(line string) (linePrefix, bool) {
+		if strings.TrimSpace(line) == "" {
+			return linePrefix{}, false
+		}
+
+		_, p := listMarker(line)
+		if !unset {
+			p.text = "- "
+		}
+		return p, true
+	})
+}
+
+// NumberedList turns the lines into a numbered list, or back into paragraphs
+// if they already are. Nested items are numbered separately.
+func NumberedList(b Block) Block {
+	unset := all(b.Text, numberRe)
+	counters := map[string]int{}
+
+	return formatLines(b, func(line string) (linePrefix, bool) {
+		if strings.TrimSpace(line) == "" {
+			return linePrefix{}, false
+		}
+
+		indent, p := listMarker(line)
+		if unset {
+			return p, true
+		}
+
+		// Restart the numbering of deeper items under a new parent.
+		for other := range counters {
+			if len(other) > len(indent) {
+				delete(counters, other)
+			}
+		}
+		counters[indent]++
+
+		p.text = strconv.Itoa(counters[indent]) + ". "
+		return p, true
+	})
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clamp(n, lo, hi int) int {
+	return min(max(n, lo), hi)
+}
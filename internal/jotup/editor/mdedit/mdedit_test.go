@@ -0,0 +1,128 @@
+package mdedit
+
+import (
+	"strings"
+	"testing"
+)
+
+// Blocks are written with ‸ for the cursor, or ‹ and › around the selection.
+
+// parseBlock parses a block written with selection markers.
+func parseBlock(t *testing.T, s string) Block {
+	t.Helper()
+
+	var b strings.Builder
+	start, end := -1, -1
+	var n int
+
+	for _, r := range s {
+		switch r {
+		case '‸':
+			start, end = n, n
+		case '‹':
+			start = n
+		case '›':
+			end = n
+		default:
+			b.WriteRune(r)
+			n++
+		}
+	}
+
+	if start < 0 || end < 0 {
+		t.Fatalf("block %q has no cursor", s)
+	}
+
+	return Block{Text: b.String(), SelStart: start, SelEnd: end}
+}
+
+// formatBlock writes the block with selection markers.
+func formatBlock(b Block) string {
+	start, end := b.selection()
+
+	if start == end {
+		return b.Text[:start] + "‸" + b.Text[start:]
+	}
+	return b.Text[:start] + "‹" + b.Text[start:end] + "›" + b.Text[end:]
+}
+
+type formatTest struct {
+	name string
+	in   string
+	out  string
+}
+
+func testFormat(t *testing.T, format Format, tests []formatTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := formatBlock(format(parseBlock(t, test.in)))
+			if out != test.out {
+				t.Errorf("expected %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestBold(t *testing.T) {
+	testFormat(t, Bold, []formatTest{
+		{"wrap selection", "a ‹word› b", "a **‹word›** b"},
+		{"unwrap selection", "a ‹**word**› b", "a ‹word› b"},
+		{"unwrap around", "a **‹word›** b", "a ‹word› b"},
+		{"trim spaces", "a‹ word ›b", "a **‹word›** b"},
+		{"each line", "‹one\ntwo›", "**‹one**\n**two›**"},
+		{"word at cursor", "a wo‸rd b", "a **wo‸rd** b"},
+		{"unwrap at cursor", "a **wo‸rd** b", "a wo‸rd b"},
+		{"empty pair", "a ‸ b", "a **‸** b"},
+		{"remove empty pair", "a **‸** b", "a ‸ b"},
+		{"multibyte", "ü ‹wörd›", "ü **‹wörd›**"},
+	})
+}
+
+func TestItalic(t *testing.T) {
+	testFormat(t, Italic, []formatTest{
+		{"wrap", "‹word›", "_‹word›_"},
+		{"unwrap", "_‹word›_", "‹word›"},
+		{"unwrap at cursor", "_wo‸rd_", "wo‸rd"},
+		{"dunder isn't italic", "__in‸it__", "___in‸it___"},
+		{"underscore bold isn't italic", "‹__bold__›", "_‹__bold__›_"},
+		{"underscore bold around", "__‹bold›__", "___‹bold›___"},
+		{"not an empty pair", "_‸_init", "__‸_init_"},
+		{"remove empty pair", "a _‸_", "a ‸"},
+	})
+}
+
+func TestCode(t *testing.T) {
+	testFormat(t, Code, []formatTest{
+		{"wrap", "run ‹go test›", "run `‹go test›`"},
+		{"unwrap", "run `‹go test›`", "run ‹go test›"},
+		{"fence isn't a span", "``‹code›``", "```‹code›```"},
+	})
+}
+
+func TestLink(t *testing.T) {
+	testFormat(t, Link, []formatTest{
+		{"empty", "a ‸", "a [‸]()"},
+		{"label", "‹jotup›", "[jotup](‸)"},
+		{"url", "‹https://example.com›", "[‸](https://example.com)"},
+		{"unlink selection", "‹[jotup](https://example.com)›", "‹jotup›"},
+		{"unlink at cursor", "see [jo‸tup](x) now", "see jotup‸ now"},
+	})
+}
+
+func TestHeading(t *testing.T) {
+	testFormat(t, Heading(2), []formatTest{
+		{"set", "ti‸tle", "## ti‸tle"},
+		{"unset", "## ti‸tle", "ti‸tle"},
+		{"change level", "# ti‸tle", "## ti‸tle"},
+		{"skip blank lines", "‹a\n\nb›", "## ‹a\n\n## b›"},
+	})
+}
+
+func TestQuote(t *testing.T) {
+	testFormat(t, Quote, []formatTest{
+		{"set", "‹a\n\nb›", "> ‹a\n>\n> b›"},
+		{"unset", "‹> a\n>\n> b›", "‹a\n\nb›"},
+	})
+}
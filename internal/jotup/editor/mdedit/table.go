@@ -0,0 +1,437 @@
+package mdedit
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// minCellWidth is the narrowest a column is padded to, which is the shortest
+// delimiter cell that can show an alignment.
+const minCellWidth = 3
+
+type align uint8
+
+const (
+	alignNone align = iota
+	alignLeft
+	alignRight
+	alignCenter
+)
+
+var delimCellRe = regexp.MustCompile(`^(:?)-+(:?)$`)
+
+// cellSpan is the trimmed content of a table cell in a line, in bytes.
+type cellSpan struct{ start, end int }
+
+// splitRow splits a table row into its cells at the pipes that aren't escaped.
+// The outer pipes are optional.
+func splitRow(line string) []cellSpan {
+	var pipes []int
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case '|':
+			pipes = append(pipes, i)
+		}
+	}
+
+	trimmed := strings.TrimSpace(line)
+	leading := strings.HasPrefix(trimmed, "|")
+	trailing := len(trimmed) > 1 && strings.HasSuffix(trimmed, "|") && !strings.HasSuffix(trimmed, `\|`)
+
+	var bounds []int // pairs of segment starts and ends
+	start := 0
+	for _, pipe := range pipes {
+		bounds = append(bounds, start, pipe)
+		start = pipe + 1
+	}
+	bounds = append(bounds, start, len(line))
+
+	if leading {
+		bounds = bounds[2:]
+	}
+	if trailing && len(bounds) > 0 {
+		bounds = bounds[:len(bounds)-2]
+	}
+
+	cells := make([]cellSpan, 0, len(bounds)/2)
+	for i := 0; i < len(bounds); i += 2 {
+		a, z := bounds[i], bounds[i+1]
+		for a < z && isSpace(line[a]) {
+			a++
+		}
+		for z > a && isSpace(line[z-1]) {
+			z--
+		}
+		cells = append(cells, cellSpan{a, z})
+	}
+
+	return cells
+}
+
+// cellIndex returns the index of the cell at the byte offset in the row, which
+// is the number of pipes before it, not counting a leading one.
+func cellIndex(line string, pos int) int {
+	n := 0
+	for i := 0; i < pos && i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '|':
+			n++
+		}
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(line), "|") {
+		n--
+	}
+	return n
+}
+
+// table is a parsed GFM pipe table. The second row is the delimiter row.
+type table struct {
+	indent string
+	rows   [][]string
+	aligns []align
+}
+
+// tableCursor is a position in a table.
+type tableCursor struct {
+	row, col int
+	offset   int // in bytes within the cell's content
+}
+
+// parseTable parses the lines of the block as a table, also returning where
+// the cursor is in it. False is returned if the lines aren't a table.
+func parseTable(b Block) (*table, tableCursor, bool) {
+	cursor, _ := b.selection()
+	lines := splitLines(b.Text)
+	if len(lines) < 2 {
+		return nil, tableCursor{}, false
+	}
+
+	first := b.Text[lines[0].start:lines[0].end]
+	t := table{
+		indent: first[:len(first)-len(strings.TrimLeft(first, " \t"))],
+		rows:   make([][]string, len(lines)),
+	}
+	var at tableCursor
+
+	for i, span := range lines {
+		line := b.Text[span.start:span.end]
+		if !strings.Contains(line, "|") {
+			return nil, tableCursor{}, false
+		}
+
+		cells := splitRow(line)
+		t.rows[i] = make([]string, len(cells))
+		for j, cell := range cells {
+			t.rows[i][j] = line[cell.start:cell.end]
+		}
+
+		if cursor < span.start || cursor > span.end {
+			continue
+		}
+
+		if len(cells) > 0 {
+			pos := cursor - span.start
+			col := clamp(cellIndex(line, pos), 0, len(cells)-1)
+			cell := cells[col]
+			at = tableCursor{
+				row:    i,
+				col:    col,
+				offset: clamp(pos-cell.start, 0, cell.end-cell.start),
+			}
+		}
+	}
+
+	for _, cell := range t.rows[1] {
+		m := delimCellRe.FindStringSubmatch(strings.ReplaceAll(cell, " ", ""))
+		if m == nil {
+			return nil, tableCursor{}, false
+		}
+
+		switch {
+		case m[1] != "" && m[2] != "":
+			t.aligns = append(t.aligns, alignCenter)
+		case m[1] != "":
+			t.aligns = append(t.aligns, alignLeft)
+		case m[2] != "":
+			t.aligns = append(t.aligns, alignRight)
+		default:
+			t.aligns = append(t.aligns, alignNone)
+		}
+	}
+
+	// Make every row as wide as the widest one.
+	cols := 0
+	for _, row := range t.rows {
+		cols = max(cols, len(row))
+	}
+	for i := range t.rows {
+		for len(t.rows[i]) < cols {
+			t.rows[i] = append(t.rows[i], "")
+		}
+	}
+	for len(t.aligns) < cols {
+		t.aligns = append(t.aligns, alignNone)
+	}
+
+	return &t, at, true
+}
+
+// isDelimRow returns true if the line is the delimiter row of a table.
+func isDelimRow(line string) bool {
+	cells := splitRow(line)
+	if len(cells) == 0 || !strings.Contains(line, "|") {
+		return false
+	}
+	for _, cell := range cells {
+		if !delimCellRe.MatchString(strings.ReplaceAll(line[cell.start:cell.end], " ", "")) {
+			return false
+		}
+	}
+	return true
+}
+
+// TableAt finds the table that the cursor is in among the lines of the block,
+// which all contain pipes. The lines above the table's header aren't part of
+// it. The character offset of the table in the block is returned along with
+// the table. False is returned if the cursor isn't in a table.
+func TableAt(b Block) (int, Block, bool) {
+	cursor, _ := b.selection()
+	lines := splitLines(b.Text)
+
+	row := 0
+	for row < len(lines)-1 && cursor > lines[row].end {
+		row++
+	}
+
+	// Look for the delimiter row below the header, which is at most one line
+	// below the cursor's.
+	delim := -1
+	for i := min(row+1, len(lines)-1); i > 0; i-- {
+		if isDelimRow(b.Text[lines[i].start:lines[i].end]) {
+			delim = i
+			break
+		}
+	}
+	if delim < 0 || row < delim-1 {
+		return 0, Block{}, false
+	}
+
+	start := lines[delim-1].start
+	offset := charOffset(b.Text, start)
+	return offset, Block{
+		Text:     b.Text[start:],
+		SelStart: b.SelStart - offset,
+		SelEnd:   b.SelEnd - offset,
+	}, true
+}
+
+// fenceRe matches a code fence, with up to three spaces before it.
+var fenceRe = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)$")
+
+// IsFence returns true if the line opens or closes a fenced code block.
+func IsFence(line string) bool {
+	return fenceRe.MatchString(line)
+}
+
+// InCodeBlock returns true if the end of the text is within a fenced code
+// block, meaning that a line following it is code.
+func InCodeBlock(text string) bool {
+	var fence string
+	for _, line := range strings.Split(text, "\n") {
+		m := fenceRe.FindStringSubmatch(line)
+		switch {
+		case m == nil:
+		case fence == "":
+			// Info strings of backtick fences can't have backticks.
+			if m[1][0] != '`' || !strings.Contains(m[2], "`") {
+				fence = m[1]
+			}
+		case m[1][0] == fence[0] && len(m[1]) >= len(fence) && strings.TrimSpace(m[2]) == "":
+			fence = ""
+		}
+	}
+	return fence != ""
+}
+
+// render returns the table aligned and padded, and the selection of the given
+// cell's content from the offset in it to the offset plus length, clamped.
+func (t *table) render(at tableCursor, length int) Block {
+	widths := make([]int, len(t.aligns))
+	for i := range widths {
+		widths[i] = minCellWidth
+	}
+	for i, row := range t.rows {
+		if i == 1 {
+			continue
+		}
+		for j, cell := range row {
+			widths[j] = max(widths[j], utf8.RuneCountInString(cell))
+		}
+	}
+
+	var out strings.Builder
+	selStart, selEnd := 0, 0
+
+	for i, row := range t.rows {
+		if i > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(t.indent)
+		out.WriteByte('|')
+
+		for j, cell := range row {
+			out.WriteByte(' ')
+
+			if i == 1 {
+				if i == at.row && j == at.col {
+					selStart, selEnd = out.Len(), out.Len()
+				}
+				out.WriteString(delimCell(t.aligns[j], widths[j]))
+			} else {
+				left, right := padding(t.aligns[j], widths[j]-utf8.RuneCountInString(cell))
+				out.WriteString(strings.Repeat(" ", left))
+
+				if i == at.row && j == at.col {
+					selStart = out.Len() + clamp(at.offset, 0, len(cell))
+					selEnd = out.Len() + clamp(at.offset+length, 0, len(cell))
+				}
+
+				out.WriteString(cell)
+				out.WriteString(strings.Repeat(" ", right))
+			}
+
+			out.WriteString(" |")
+		}
+	}
+
+	return withSelection(out.String(), selStart, selEnd)
+}
+
+func delimCell(a align, width int) string {
+	switch a {
+	case alignLeft:
+		return ":" + strings.Repeat("-", width-1)
+	case alignRight:
+		return strings.Repeat("-", width-1) + ":"
+	case alignCenter:
+		return ":" + strings.Repeat("-", width-2) + ":"
+	default:
+		return strings.Repeat("-", width)
+	}
+}
+
+// padding returns the spaces before and after a cell's content.
+func padding(a align, spaces int) (int, int) {
+	switch a {
+	case alignRight:
+		return spaces, 0
+	case alignCenter:
+		return spaces / 2, spaces - spaces/2
+	default:
+		return 0, spaces
+	}
+}
+
+// FormatTable aligns the columns of the table, keeping the cursor in its cell.
+// False is returned if the lines of the block aren't a table.
+func FormatTable(b Block) (Block, bool) {
+	t, at, ok := parseTable(b)
+	if !ok {
+		return b, false
+	}
+	return t.render(at, 0), true
+}
+
+// NextCell formats the table and selects the content of the next cell, or of
+// the previous one if backward is true. A new row is added when moving past
+// the last cell. The delimiter row is skipped.
+func NextCell(b Block, backward bool) (Block, bool) {
+	t, at, ok := parseTable(b)
+	if !ok {
+		return b, false
+	}
+
+	cols := len(t.aligns)
+
+	if backward {
+		switch {
+		case at.col > 0:
+			at.col--
+		case at.row > 0:
+			at.row--
+			at.col = cols - 1
+		}
+	} else {
+		switch {
+		case at.col < cols-1:
+			at.col++
+		case at.row < len(t.rows)-1:
+			at.row++
+			at.col = 0
+		default:
+			t.rows = append(t.rows, make([]string, cols))
+			at.row++
+			at.col = 0
+		}
+	}
+
+	if at.row == 1 {
+		if backward {
+			at.row = 0
+		} else if len(t.rows) > 2 {
+			at.row = 2
+		} else {
+			t.rows = append(t.rows, make([]string, cols))
+			at.row = 2
+		}
+	}
+
+	// Select the whole content, so that typing replaces it.
+	at.offset = 0
+	return t.render(at, len(t.rows[at.row][at.col])), true
+}
+
+// InsertRow adds an empty row below the cursor's, or below the header, and
+// moves the cursor into it.
+func InsertRow(b Block) (Block, bool) {
+	t, at, ok := parseTable(b)
+	if !ok {
+		return b, false
+	}
+
+	row := max(at.row+1, 2)
+	t.rows = append(t.rows[:row], append([][]string{make([]string, len(t.aligns))}, t.rows[row:]...)...)
+
+	return t.render(tableCursor{row: row, col: at.col}, 0), true
+}
+
+// InsertColumn adds an empty column after the cursor's and moves the cursor
+// into it.
+func InsertColumn(b Block) (Block, bool) {
+	t, at, ok := parseTable(b)
+	if !ok {
+		return b, false
+	}
+
+	col := at.col + 1
+	for i, row := range t.rows {
+		cell := ""
+		if i == 1 {
+			cell = "---"
+		}
+		t.rows[i] = append(row[:col], append([]string{cell}, row[col:]...)...)
+	}
+	t.aligns = append(t.aligns[:col], append([]align{alignNone}, t.aligns[col:]...)...)
+
+	row := at.row
+	if row == 1 {
+		row = 0
+	}
+	return t.render(tableCursor{row: row, col: col}, 0), true
+}
@@ -0,0 +1,284 @@
+package mdedit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		rows   [][]string
+		aligns []align
+		at     tableCursor
+	}{
+		{
+			name:   "outer pipes",
+			in:     "| a | b |\n|---|:-:|\n| c‸ | d |",
+			rows:   [][]string{{"a", "b"}, {"---", ":-:"}, {"c", "d"}},
+			aligns: []align{alignNone, alignCenter},
+			at:     tableCursor{row: 2, col: 0, offset: 1},
+		},
+		{
+			name:   "no outer pipes",
+			in:     "a | b‸\n:-- | --:",
+			rows:   [][]string{{"a", "b"}, {":--", "--:"}},
+			aligns: []align{alignLeft, alignRight},
+			at:     tableCursor{row: 0, col: 1, offset: 1},
+		},
+		{
+			name:   "escaped pipe",
+			in:     `| a \| b | ‸c |` + "\n| - | - |",
+			rows:   [][]string{{`a \| b`, "c"}, {"-", "-"}},
+			aligns: []align{alignNone, alignNone},
+			at:     tableCursor{row: 0, col: 1, offset: 0},
+		},
+		{
+			name:   "short row",
+			in:     "| a | b |\n| - | - |\n| c‸ |",
+			rows:   [][]string{{"a", "b"}, {"-", "-"}, {"c", ""}},
+			aligns: []align{alignNone, alignNone},
+			at:     tableCursor{row: 2, col: 0, offset: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			table, at, ok := parseTable(parseBlock(t, test.in))
+			if !ok {
+				t.Fatal("not a table")
+			}
+			if !reflect.DeepEqual(table.rows, test.rows) {
+				t.Errorf("expected rows %q, got %q", test.rows, table.rows)
+			}
+			if !reflect.DeepEqual(table.aligns, test.aligns) {
+				t.Errorf("expected aligns %v, got %v", test.aligns, table.aligns)
+			}
+			if at != test.at {
+				t.Errorf("expected cursor %+v, got %+v", test.at, at)
+			}
+		})
+	}
+
+	for _, in := range []string{"| a |‸", "| a |‸\n| b |", "| a |‸\nb"} {
+		if _, _, ok := parseTable(parseBlock(t, in)); ok {
+			t.Errorf("%q parsed as a table", in)
+		}
+	}
+}
+
+func TestNextCell(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		backward bool
+		out      string
+	}{
+		{
+			name: "next",
+			in:   "|a‸|bb|\n|-|-|\n|c|d|",
+			out:  "| a   | ‹bb›  |\n| --- | --- |\n| c   | d   |",
+		},
+		{
+			name: "next row skips delimiter",
+			in:   "|a|b‸|\n|-|-|\n|c|d|",
+			out:  "| a   | b   |\n| --- | --- |\n| ‹c›   | d   |",
+		},
+		{
+			name: "new row",
+			in:   "|a|b|\n|-|-|\n|c|d‸|",
+			out:  "| a   | b   |\n| --- | --- |\n| c   | d   |\n| ‸    |     |",
+		},
+		{
+			name: "new row after header",
+			in:   "|a|b‸|\n|-|-|",
+			out:  "| a   | b   |\n| --- | --- |\n| ‸    |     |",
+		},
+		{
+			name:     "previous",
+			in:       "|a|b|\n|-|-|\n|c|‸d|",
+			backward: true,
+			out:      "| a   | b   |\n| --- | --- |\n| ‹c›   | d   |",
+		},
+		{
+			name:     "previous row skips delimiter",
+			in:       "|a|b|\n|-|-|\n|‸c|d|",
+			backward: true,
+			out:      "| a   | ‹b›   |\n| --- | --- |\n| c   | d   |",
+		},
+		{
+			name:     "first cell",
+			in:       "|‸a|b|\n|-|-|",
+			backward: true,
+			out:      "| ‹a›   | b   |\n| --- | --- |",
+		},
+		{
+			name: "alignment",
+			in:   "|a‸|b|c|\n|:-|-:|:-:|\n|long|x|y|",
+			out:  "| a    |   ‹b› |  c  |\n| :--- | --: | :-: |\n| long |   x |  y  |",
+		},
+		{
+			name: "indented",
+			in:   "  |a‸|b|\n  |-|-|",
+			out:  "  | a   | ‹b›   |\n  | --- | --- |",
+		},
+		{
+			name: "multibyte",
+			in:   "|ü‸|b|\n|-|-|\n|ñandú|d|",
+			out:  "| ü     | ‹b›   |\n| ----- | --- |\n| ñandú | d   |",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, ok := NextCell(parseBlock(t, test.in), test.backward)
+			if !ok {
+				t.Fatal("not a table")
+			}
+			if got := formatBlock(out); got != test.out {
+				t.Errorf("expected %q, got %q", test.out, got)
+			}
+		})
+	}
+}
+
+func TestInsertRow(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "below cursor",
+			in:   "|a|b|\n|-|-|\n|c‸|d|\n|e|f|",
+			out:  "| a   | b   |\n| --- | --- |\n| c   | d   |\n| ‸    |     |\n| e   | f   |",
+		},
+		{
+			name: "below header",
+			in:   "|a|b‸|\n|-|-|\n|c|d|",
+			out:  "| a   | b   |\n| --- | --- |\n|     | ‸    |\n| c   | d   |",
+		},
+		{
+			name: "below delimiter",
+			in:   "|a|b|\n|-‸|-|",
+			out:  "| a   | b   |\n| --- | --- |\n| ‸    |     |",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, ok := InsertRow(parseBlock(t, test.in))
+			if !ok {
+				t.Fatal("not a table")
+			}
+			if got := formatBlock(out); got != test.out {
+				t.Errorf("expected %q, got %q", test.out, got)
+			}
+		})
+	}
+}
+
+func TestInsertColumn(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "after cursor",
+			in:   "|a‸|b|\n|-|-:|\n|c|d|",
+			out:  "| a   | ‸    |   b |\n| --- | --- | --: |\n| c   |     |   d |",
+		},
+		{
+			name: "last column",
+			in:   "|a|b|\n|-|-|\n|c|d‸|",
+			out:  "| a   | b   |     |\n| --- | --- | --- |\n| c   | d   | ‸    |",
+		},
+		{
+			name: "from delimiter",
+			in:   "|a|b|\n|-‸|-|",
+			out:  "| a   | ‸    | b   |\n| --- | --- | --- |",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out, ok := InsertColumn(parseBlock(t, test.in))
+			if !ok {
+				t.Fatal("not a table")
+			}
+			if got := formatBlock(out); got != test.out {
+				t.Errorf("expected %q, got %q", test.out, got)
+			}
+		})
+	}
+}
+
+func TestTableAt(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		table string // empty if the cursor isn't in a table
+	}{
+		{"header", "|a‸|\n|-|\n|b|", "|a‸|\n|-|\n|b|"},
+		{"delimiter", "|a|\n|-‸|\n|b|", "|a|\n|-‸|\n|b|"},
+		{"body", "|a|\n|-|\n|b‸|", "|a|\n|-|\n|b‸|"},
+		{"pipe line above", "x | y\n|a|\n|-|\n|b‸|", "|a|\n|-|\n|b‸|"},
+		{"on the line above", "x | y‸\n|a|\n|-|\n|b|", ""},
+		{"no delimiter", "|a|\n|b‸|", ""},
+		{"delimiter is first", "|-|\n|b‸|", ""},
+		{"multibyte above", "ü | ñ\n|a|\n|-|\n|b‸|", "|a|\n|-|\n|b‸|"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := parseBlock(t, test.in)
+
+			offset, table, ok := TableAt(in)
+			if !ok {
+				if test.table != "" {
+					t.Fatal("no table at the cursor")
+				}
+				return
+			}
+			if test.table == "" {
+				t.Fatalf("found table %q", formatBlock(table))
+			}
+			if got := formatBlock(table); got != test.table {
+				t.Errorf("expected table %q, got %q", test.table, got)
+			}
+			if table.SelStart+offset != in.SelStart {
+				t.Errorf("offset %d doesn't match the cursor", offset)
+			}
+		})
+	}
+}
+
+func TestInCodeBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		code bool
+	}{
+		{"none", "text\n| a |", false},
+		{"open", "text\n```go\n", true},
+		{"closed", "```\ncode\n```\n", false},
+		{"tildes", "~~~\ncode\n", true},
+		{"other fence inside", "~~~\n```\n", true},
+		{"shorter fence inside", "````\n```\n", true},
+		{"longer closing fence", "```\ncode\n`````\n", false},
+		{"closing fence with text", "```\n``` x\n", true},
+		{"indented", "   ```\n", true},
+		{"indented too far", "    ```\n", false},
+		{"backtick in info", "``` a`b\n", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := InCodeBlock(test.text); code != test.code {
+				t.Errorf("expected %v, got %v", test.code, code)
+			}
+		})
+	}
+}
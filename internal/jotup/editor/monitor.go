@@ -0,0 +1,113 @@
+package editor
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4-sourceview/pkg/gtksource/v5"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// monitorDelay is the delay in milliseconds to wait for a burst of file events
+// to settle before checking the file on disk.
+const monitorDelay = 250
+
+// monitorFile starts monitoring the current file for changes done by other
+// programs. The previous monitor, if any, is stopped.
+func (v *View) monitorFile(file gio.Filer) {
+	v.unmonitorFile()
+
+	if file == nil {
+		return
+	}
+
+	m, err := file.Monitor(v.ctx, gio.FileMonitorNone)
+	if err != nil {
+		log.Println("cannot monitor file:", err)
+		return
+	}
+
+	v.monitor = gio.BaseFileMonitor(m)
+	v.monitor.ConnectChanged(func(_, _ gio.Filer, event gio.FileMonitorEvent) {
+		switch event {
+		case gio.FileMonitorEventChanged, gio.FileMonitorEventAttributeChanged:
+			// Wait for ChangesDoneHint instead.
+			return
+		}
+
+		if v.monitorHandle > 0 {
+			glib.SourceRemove(v.monitorHandle)
+		}
+		v.monitorHandle = glib.TimeoutAdd(monitorDelay, func() {
+			v.monitorHandle = 0
+			v.live().checkFileOnDisk()
+		})
+	})
+}
+
+func (v *View) unmonitorFile() {
+	if v.monitorHandle > 0 {
+		glib.SourceRemove(v.monitorHandle)
+		v.monitorHandle = 0
+	}
+
+	if v.monitor != nil {
+		v.monitor.Cancel()
+		v.monitor = nil
+	}
+}
+
+// checkFileOnDisk checks if the file was modified by another program. If the
+// buffer has no unsaved changes, then it's silently reloaded. Otherwise, the
+// user is asked what to do.
+func (v *View) checkFileOnDisk() {
+	// Ignore our own writes.
+	if v.saving || v.untrack {
+		return
+	}
+
+	v.File.CheckFileOnDisk()
+
+	switch {
+	case v.File.IsDeleted():
+		v.Toast.Show("The file was deleted by another program.")
+	case v.File.IsExternallyModified():
+		if !v.unsaved {
+			v.reload()
+			return
+		}
+
+		v.Toast.Show(
+			"The file was changed by another program.",
+			gtkutil.ActionData{Name: "Reload", Func: func() {
+				v.Toast.Dismiss()
+				v.DiscardChanges()
+				v.reload()
+			}},
+			gtkutil.ActionData{Name: "Keep Mine", Func: func() {
+				v.Toast.Dismiss()
+				v.keepMine = true
+			}},
+		)
+	}
+}
+
+// reload refreshes the buffer from disk while keeping the cursor position.
+func (v *View) reload() {
+	offset := v.Buffer.IterAtMark(v.Buffer.GetInsert()).Offset()
+
+	v.refresh(func() {
+		v.Buffer.PlaceCursor(v.Buffer.IterAtOffset(offset))
+	})
+}
+
+// saverFlags returns the flags for the next FileSaver.
+func (v *View) saverFlags() gtksource.FileSaverFlags {
+	if v.keepMine {
+		// The user chose to overwrite the external changes, so don't fail
+		// because of them.
+		return gtksource.SourceFileSaverFlagsIgnoreModificationTime
+	}
+	return gtksource.SourceFileSaverFlagsNone
+}
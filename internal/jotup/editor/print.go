@@ -0,0 +1,651 @@
+package editor
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diamondburned/gotk4-lasem/pkg/lasem"
+	"github.com/diamondburned/gotk4-sourceview/pkg/gtksource/v5"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotk4/pkg/pangocairo"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/extern/pangox"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/mathext"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// printSourceKey is the print setting that's true if the Markdown source is
+// printed instead of the rendered Markdown.
+const printSourceKey = "jotup-print-source"
+
+// Print shows the print dialog for the current file. By default, the rendered
+// Markdown is printed. The dialog has a tab to print the source instead.
+func (v *View) Print() {
+	start, end := v.Buffer.Bounds()
+	src := []byte(v.Buffer.Text(start, end, false))
+
+	v.SetBusy(false)
+
+	gtkutil.Async(v.ctx, func() func() {
+		// KaTeX may have to be fetched first.
+		mathml := renderPrintMath(src)
+
+		return func() {
+			v.UnsetBusy()
+			v.print(src, mathml)
+		}
+	})
+}
+
+func (v *View) print(src []byte, mathml map[printMathKey]string) {
+	p := printer{view: v, src: src, mathml: mathml}
+
+	p.op = gtk.NewPrintOperation()
+	p.op.SetJobName(filepath.Base(v.path))
+	p.op.SetEmbedPageSetup(true)
+	p.op.SetCustomTabLabel("Markdown")
+	if v.printSettings != nil {
+		p.op.SetPrintSettings(v.printSettings)
+		p.source = v.printSettings.Bool(printSourceKey)
+	}
+	p.op.ConnectCreateCustomWidget(func() *coreglib.Object {
+		check := gtk.NewCheckButtonWithLabel("Print the Markdown source instead")
+		check.SetActive(p.source)
+		check.SetMarginTop(12)
+		check.SetMarginStart(12)
+		return coreglib.InternObject(check)
+	})
+	p.op.ConnectCustomWidgetApply(func(w gtk.Widgetter) {
+		p.source = w.(*gtk.CheckButton).Active()
+	})
+	p.op.ConnectBeginPrint(p.begin)
+	p.op.ConnectPaginate(p.paginate)
+	p.op.ConnectDrawPage(p.drawPage)
+
+	window := app.WindowFromContext(v.ctx)
+
+	res, err := p.op.Run(gtk.PrintOperationActionPrintDialog, &window.Window)
+	if err != nil {
+		v.Toast.Show("Error: " + err.Error())
+		return
+	}
+
+	if res == gtk.PrintOperationResultApply {
+		// Remember the settings and the choice for the next print.
+		settings := p.op.PrintSettings()
+		settings.SetBool(printSourceKey, p.source)
+		v.printSettings = settings
+	}
+}
+
+type printer struct {
+	op     *gtk.PrintOperation
+	view   *View
+	source bool
+
+	src    []byte
+	mathml map[printMathKey]string
+
+	// source mode
+	compositor *gtksource.PrintCompositor
+
+	// rendered mode
+	layouts []*pango.Layout // keep the lines alive
+	pages   [][]printLine
+}
+
+type printLine struct {
+	line  *pango.LayoutLine
+	x, y  float64 // y is the baseline
+	maths []placedMath
+}
+
+// placedMath is math drawn at x from the start of its line.
+type placedMath struct {
+	math *printMath
+	x    float64
+}
+
+func (p *printer) begin(ctx *gtk.PrintContext) {
+	if p.source {
+		p.compositor = gtksource.NewPrintCompositorFromView(p.view.Source)
+		p.compositor.SetHighlightSyntax(highlightSyntax.Value())
+		p.compositor.SetTabWidth(uint(tabWidth.Value()))
+		p.compositor.SetHeaderFormat(true, filepath.Base(p.view.path), "", "")
+		p.compositor.SetFooterFormat(false, "", "%N / %Q", "")
+		p.compositor.SetPrintHeader(true)
+		p.compositor.SetPrintFooter(true)
+		if showLineNumbers.Value() {
+			p.compositor.SetPrintLineNumbers(1)
+		}
+		if lineWrap.Value() {
+			p.compositor.SetWrapMode(gtk.WrapWordChar)
+		}
+		return
+	}
+
+	blocks := renderPrintBlocks(p.src, p.mathml, ctx.DPIX())
+	p.layoutBlocks(ctx, blocks)
+	p.op.SetNPages(len(p.pages))
+}
+
+func (p *printer) paginate(ctx *gtk.PrintContext) bool {
+	if !p.source {
+		return true
+	}
+
+	if !p.compositor.Paginate(ctx) {
+		return false
+	}
+
+	p.op.SetNPages(p.compositor.NPages())
+	return true
+}
+
+func (p *printer) drawPage(ctx *gtk.PrintContext, page int) {
+	if p.source {
+		p.compositor.DrawPage(ctx, page)
+		return
+	}
+
+	if page < 0 || page >= len(p.pages) {
+		return
+	}
+
+	cr := ctx.CairoContext()
+	cr.SetSourceRGB(0, 0, 0)
+
+	for _, line := range p.pages[page] {
+		cr.MoveTo(line.x, line.y)
+		pangocairo.ShowLayoutLine(cr, line.line)
+
+		for _, m := range line.maths {
+			m.math.view.Render(cr, line.x+m.x, line.y-m.math.baseline)
+		}
+	}
+}
+
+// printIndent is the width of one indentation level in points.
+const printIndent = 18
+
+// layoutBlocks lays out each block into its own pango.Layout and paginates the
+// lines of all layouts into pages.
+func (p *printer) layoutBlocks(ctx *gtk.PrintContext, blocks []printBlock) {
+	width := ctx.Width()
+	height := ctx.Height()
+
+	var y float64
+	page := []printLine{}
+
+	for i, block := range blocks {
+		indent := float64(block.indent * printIndent)
+
+		layout := ctx.CreatePangoLayout()
+		layout.SetWidth(int((width - indent) * pango.SCALE))
+		layout.SetWrap(pango.WrapWordChar)
+		layout.SetMarkup(block.markup, -1)
+		if block.center {
+			layout.SetAlignment(pango.AlignCenter)
+		}
+		p.layouts = append(p.layouts, layout)
+
+		maths := shapeMaths(layout, block.maths)
+
+		// Space out paragraphs, except on the top of a page.
+		if i > 0 && len(page) > 0 {
+			y += block.spacing(layout)
+		}
+
+		iter := layout.Iter()
+		for n := 0; ; n++ {
+			_, logical := iter.LineExtents()
+			lineHeight := float64(logical.Height()) / pango.SCALE
+			ascent := float64(iter.Baseline()-logical.Y()) / pango.SCALE
+
+			if y+lineHeight > height && len(page) > 0 {
+				p.pages = append(p.pages, page)
+				page = []printLine{}
+				y = 0
+			}
+
+			page = append(page, printLine{
+				line:  iter.LineReadonly(),
+				x:     indent + float64(logical.X())/pango.SCALE,
+				y:     y + ascent,
+				maths: maths[n],
+			})
+			y += lineHeight
+
+			if !iter.NextLine() {
+				break
+			}
+		}
+	}
+
+	if len(page) > 0 || len(p.pages) == 0 {
+		p.pages = append(p.pages, page)
+	}
+}
+
+// shapeMaths reserves the space of each math at its placeholder in the layout,
+// returning the maths placed on each line.
+func shapeMaths(layout *pango.Layout, maths []*printMath) map[int][]placedMath {
+	if len(maths) == 0 {
+		return nil
+	}
+
+	attrs := layout.Attributes()
+	if attrs == nil {
+		attrs = pango.NewAttrList()
+	}
+
+	var indices []int
+	text := layout.Text()
+
+	for i := 0; len(indices) < len(maths); i += len(printMathChar) {
+		j := strings.Index(text[i:], printMathChar)
+		if j < 0 {
+			break
+		}
+		i += j
+
+		m := maths[len(indices)]
+		rect := pango.NewRectangle(
+			0, int(-m.baseline*pango.SCALE),
+			int(m.width*pango.SCALE), int(m.height*pango.SCALE),
+		)
+
+		attr := pango.NewAttrShape(&rect, &rect)
+		pangox.SetAttrRange(attr, i, i+len(printMathChar))
+		attrs.Insert(attr)
+
+		indices = append(indices, i)
+	}
+
+	layout.SetAttributes(attrs)
+
+	placed := make(map[int][]placedMath, len(indices))
+	for i, index := range indices {
+		line, x := layout.IndexToLineX(index, false)
+		placed[line] = append(placed[line], placedMath{
+			math: maths[i],
+			x:    float64(x) / pango.SCALE,
+		})
+	}
+
+	return placed
+}
+
+// printBlock is a single paragraph in the printed document.
+type printBlock struct {
+	markup string
+	maths  []*printMath // at each printMathChar in the markup
+	indent int
+	tight  bool // no spacing before
+	center bool
+}
+
+func (b printBlock) spacing(layout *pango.Layout) float64 {
+	if b.tight {
+		return 0
+	}
+	_, logical := layout.Iter().LineExtents()
+	return float64(logical.Height()) / pango.SCALE / 2
+}
+
+var printMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, mathext.Extension),
+)
+
+// printMathChar is the object replacement character that holds the place of
+// math in the markup of a block.
+const printMathChar = "\uFFFC"
+
+// printMathKey identifies the MathML of math in the printed document.
+type printMathKey struct {
+	tex     string
+	display bool
+}
+
+// renderPrintMath renders all math in the Markdown source into MathML with
+// KaTeX. Math that can't be rendered is left out and printed as TeX. It may
+// block to fetch KaTeX, so it must be called in a goroutine.
+func renderPrintMath(src []byte) map[printMathKey]string {
+	var keys []printMathKey
+
+	doc := printMarkdown.Parser().Parse(text.NewReader(src))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			switch n := n.(type) {
+			case *mathext.InlineMath:
+				keys = append(keys, printMathKey{string(n.TeX(src)), false})
+			case *mathext.BlockMath:
+				keys = append(keys, printMathKey{string(n.TeX(src)), true})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	exportMath.Lock()
+	defer exportMath.Unlock()
+
+	module := loadMath()
+	if module == nil {
+		return nil
+	}
+
+	mathml := make(map[printMathKey]string, len(keys))
+	for _, key := range keys {
+		if _, ok := mathml[key]; ok {
+			continue
+		}
+
+		ml, err := module.Render(key.tex, key.display)
+		if err != nil {
+			log.Println("print: cannot render math:", err)
+			continue
+		}
+
+		mathml[key] = ml
+	}
+
+	return mathml
+}
+
+// printMath is math rendered by lasem to draw onto the print context. The
+// sizes are in the context's units.
+type printMath struct {
+	doc      *lasem.DOMDocument
+	view     *lasem.DOMView
+	width    float64
+	height   float64
+	baseline float64 // from the top
+}
+
+func newPrintMath(mathml string, dpi float64) *printMath {
+	doc, err := lasem.NewDOMDocumentFromMemory(mathml)
+	if err != nil {
+		log.Println("print: cannot parse MathML:", err)
+		return nil
+	}
+
+	view := lasem.BaseDOMView(doc.CreateView())
+	view.SetResolution(dpi)
+
+	// The size is in points.
+	w, h, baseline := view.Size()
+	scale := dpi / 72
+
+	return &printMath{
+		doc:      doc,
+		view:     view,
+		width:    w * scale,
+		height:   h * scale,
+		baseline: baseline * scale,
+	}
+}
+
+// renderPrintBlocks renders the given Markdown source into a list of Pango
+// markup blocks. Math is drawn from its MathML in the map, or printed as its
+// TeX source if it's missing.
+func renderPrintBlocks(src []byte, mathml map[printMathKey]string, dpi float64) []printBlock {
+	r := printRenderer{src: src, mathml: mathml, dpi: dpi}
+	r.blocks(printMarkdown.Parser().Parse(text.NewReader(src)))
+	return r.out
+}
+
+type printRenderer struct {
+	out    []printBlock
+	src    []byte
+	mathml map[printMathKey]string
+	dpi    float64
+	maths  []*printMath // for the next block
+	indent int
+	quote  int
+	prefix string // list marker for the next block
+	tight  bool
+}
+
+var printHeadingSizes = [...]string{
+	1: "xx-large",
+	2: "x-large",
+	3: "large",
+	4: "medium",
+	5: "small",
+	6: "x-small",
+}
+
+func (r *printRenderer) emit(markup string) {
+	if r.prefix != "" {
+		markup = r.prefix + markup
+		r.prefix = ""
+	}
+	if r.quote > 0 {
+		markup = `<span foreground="#789922">` + markup + `</span>`
+	}
+	r.out = append(r.out, printBlock{
+		markup: markup,
+		maths:  r.maths,
+		indent: r.indent,
+		tight:  r.tight,
+	})
+	r.maths = nil
+	r.tight = false
+}
+
+// math returns the placeholder markup of the math and queues it for the next
+// block, or the TeX source if there's no MathML for it.
+func (r *printRenderer) math(tex []byte, display bool) string {
+	if ml, ok := r.mathml[printMathKey{string(tex), display}]; ok {
+		if m := newPrintMath(ml, r.dpi); m != nil {
+			r.maths = append(r.maths, m)
+			return printMathChar
+		}
+	}
+	return "<tt><i>" + html.EscapeString(string(tex)) + "</i></tt>"
+}
+
+func (r *printRenderer) blocks(parent ast.Node) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		r.block(n)
+	}
+}
+
+func (r *printRenderer) block(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		size := printHeadingSizes[n.Level]
+		r.emit(fmt.Sprintf(`<span weight="bold" size="%s">%s</span>`, size, r.inlines(n)))
+
+	case *ast.Paragraph, *ast.TextBlock:
+		r.emit(r.inlines(n))
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+		r.emit("<tt>" + html.EscapeString(strings.TrimSuffix(r.lines(n), "\n")) + "</tt>")
+
+	case *mathext.BlockMath:
+		r.emit(r.math(n.TeX(r.src), true))
+		r.out[len(r.out)-1].center = true
+
+	case *ast.ThematicBreak:
+		r.emit(`<span foreground="#808080">` + strings.Repeat("─", 40) + `</span>`)
+
+	case *ast.Blockquote:
+		r.quote++
+		r.indent++
+		r.blocks(n)
+		r.indent--
+		r.quote--
+
+	case *ast.List:
+		num := n.Start
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			if n.IsOrdered() {
+				r.prefix = fmt.Sprintf("%d%c ", num, n.Marker)
+				num++
+			} else {
+				r.prefix = "• "
+			}
+			r.tight = n.IsTight && item != n.FirstChild()
+			r.indent++
+			r.blocks(item)
+			r.indent--
+		}
+
+	case *extast.Table:
+		r.emit("<tt>" + html.EscapeString(r.table(n)) + "</tt>")
+
+	default:
+		r.blocks(n)
+	}
+}
+
+func (r *printRenderer) lines(n ast.Node) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		b.Write(line.Value(r.src))
+	}
+	return b.String()
+}
+
+func (r *printRenderer) inlines(parent ast.Node) string {
+	var b strings.Builder
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		r.inline(&b, n)
+	}
+	return b.String()
+}
+
+func (r *printRenderer) inline(b *strings.Builder, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Text:
+		b.WriteString(html.EscapeString(string(n.Segment.Value(r.src))))
+		switch {
+		case n.HardLineBreak():
+			b.WriteByte('\n')
+		case n.SoftLineBreak():
+			b.WriteByte(' ')
+		}
+	case *ast.String:
+		b.WriteString(html.EscapeString(string(n.Value)))
+	case *ast.CodeSpan:
+		b.WriteString("<tt>" + html.EscapeString(string(n.Text(r.src))) + "</tt>")
+	case *ast.Emphasis:
+		tag := "i"
+		if n.Level > 1 {
+			tag = "b"
+		}
+		b.WriteString("<" + tag + ">" + r.inlines(n) + "</" + tag + ">")
+	case *extast.Strikethrough:
+		b.WriteString("<s>" + r.inlines(n) + "</s>")
+	case *ast.Link:
+		b.WriteString("<u>" + r.inlines(n) + "</u>")
+	case *ast.AutoLink:
+		b.WriteString("<u>" + html.EscapeString(string(n.URL(r.src))) + "</u>")
+	case *ast.Image:
+		b.WriteString("<i>[" + html.EscapeString(string(n.Text(r.src))) + "]</i>")
+	case *extast.TaskCheckBox:
+		if n.IsChecked {
+			b.WriteString("☑ ")
+		} else {
+			b.WriteString("☐ ")
+		}
+	case *mathext.InlineMath:
+		b.WriteString(r.math(n.TeX(r.src), false))
+	case *ast.RawHTML:
+		segs := n.Segments
+		for i := 0; i < segs.Len(); i++ {
+			seg := segs.At(i)
+			b.WriteString(html.EscapeString(string(seg.Value(r.src))))
+		}
+	default:
+		b.WriteString(r.inlines(n))
+	}
+}
+
+// table renders the table into a monospace box-drawn grid.
+func (r *printRenderer) table(table *extast.Table) string {
+	var rows [][]string
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []string
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			cells = append(cells, string(cell.Text(r.src)))
+		}
+		rows = append(rows, cells)
+	}
+
+	widths := make([]int, len(table.Alignments))
+	for _, row := range rows {
+		for i, cell := range row {
+			if i < len(widths) {
+				if w := utf8.RuneCountInString(cell); w > widths[i] {
+					widths[i] = w
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		if i == 1 {
+			b.WriteString("├")
+			for j, w := range widths {
+				if j > 0 {
+					b.WriteString("┼")
+				}
+				b.WriteString(strings.Repeat("─", w+2))
+			}
+			b.WriteString("┤\n")
+		}
+
+		b.WriteString("│")
+		for j, w := range widths {
+			var cell string
+			if j < len(row) {
+				cell = row[j]
+			}
+			b.WriteString(" ")
+			b.WriteString(padCell(cell, w, table.Alignments[j]))
+			b.WriteString(" │")
+		}
+		if i < len(rows)-1 {
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.String()
+}
+
+func padCell(cell string, width int, align extast.Alignment) string {
+	pad := width - utf8.RuneCountInString(cell)
+	if pad <= 0 {
+		return cell
+	}
+
+	switch align {
+	case extast.AlignRight:
+		return strings.Repeat(" ", pad) + cell
+	case extast.AlignCenter:
+		return strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+	default:
+		return cell + strings.Repeat(" ", pad)
+	}
+}
@@ -0,0 +1,197 @@
+package editor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// swapInterval is the number of seconds after an edit before the buffer is
+// written to its swap file.
+const swapInterval = 10
+
+// swapPath returns the path to the swap file of the given file. Swap files live
+// in the cache directory and are named after the hash of the file path.
+func swapPath(ctx context.Context, path string) string {
+	hash := sha256.Sum256([]byte(path))
+	name := hex.EncodeToString(hash[:8]) + "-" + filepath.Base(path) + ".swp"
+
+	if app := app.FromContext(ctx); app != nil {
+		return app.CachePath("swap", name)
+	}
+	return filepath.Join(os.TempDir(), "jotup", "swap", name)
+}
+
+// swapOps serializes the writes and removals of swap files, which happen in
+// goroutines. Each request bumps the generation of its path, and a request is
+// skipped if a newer one came in before it ran, so a late write can't bring
+// back a removed swap file.
+var swapOps = struct {
+	mu   sync.Mutex // guards gens
+	gens map[string]uint64
+	io   sync.Mutex // held while writing or removing
+}{
+	gens: make(map[string]uint64),
+}
+
+// doSwapOp runs the operation on the swap file at path in a goroutine.
+func doSwapOp(path string, op func()) {
+	swapOps.mu.Lock()
+	swapOps.gens[path]++
+	gen := swapOps.gens[path]
+	swapOps.mu.Unlock()
+
+	go func() {
+		swapOps.io.Lock()
+		defer swapOps.io.Unlock()
+
+		swapOps.mu.Lock()
+		latest := swapOps.gens[path] == gen
+		swapOps.mu.Unlock()
+
+		if latest {
+			op()
+		}
+	}()
+}
+
+// removeSwapFile asynchronously removes the swap file at path.
+func removeSwapFile(path string) {
+	doSwapOp(path, func() { os.Remove(path) })
+}
+
+// queueSwap schedules the buffer to be written into the swap file if it's not
+// already scheduled.
+func (v *View) queueSwap() {
+	if v.swapHandle > 0 || v.path == "" {
+		return
+	}
+
+	v.swapHandle = glib.TimeoutSecondsAdd(swapInterval, func() {
+		v.swapHandle = 0
+		v.live().writeSwap()
+	})
+}
+
+// writeSwap asynchronously writes the unsaved buffer into the swap file.
+func (v *View) writeSwap() {
+	if !v.unsaved || v.path == "" {
+		return
+	}
+
+	start, end := v.Buffer.Bounds()
+	text := v.Buffer.Text(start, end, false)
+	path := swapPath(v.ctx, v.path)
+
+	doSwapOp(path, func() {
+		if err := writeFileAtomic(path, []byte(text)); err != nil {
+			log.Println("cannot write swap file:", err)
+		}
+	})
+}
+
+// removeSwap removes the swap file of the current file, if any.
+func (v *View) removeSwap() {
+	if v.swapHandle > 0 {
+		glib.SourceRemove(v.swapHandle)
+		v.swapHandle = 0
+	}
+
+	if v.path == "" {
+		return
+	}
+
+	removeSwapFile(swapPath(v.ctx, v.path))
+}
+
+// checkSwap checks if the currently loaded file has a swap file left over from
+// a crash. If it does, and its content differs from the file, then the user is
+// asked whether to restore it.
+func (v *View) checkSwap() {
+	path := swapPath(v.ctx, v.path)
+	file := v.path
+
+	start, end := v.Buffer.Bounds()
+	text := v.Buffer.Text(start, end, false)
+
+	gtkutil.Async(v.ctx, func() func() {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+
+		swap := string(b)
+		if swap == text {
+			removeSwapFile(path)
+			return nil
+		}
+
+		return func() {
+			// Make sure we're still on the same file.
+			if v.path != file {
+				return
+			}
+
+			v := v.live()
+			v.Toast.Show(
+				"Found unsaved changes from a previous session.",
+				gtkutil.ActionData{Name: "Restore", Func: func() {
+					v.Toast.Dismiss()
+					v.restoreSwap(file, swap)
+				}},
+				gtkutil.ActionData{Name: "Discard", Func: func() {
+					v.Toast.Dismiss()
+					removeSwapFile(path)
+				}},
+			)
+		}
+	})
+}
+
+// restoreSwap replaces the buffer's content with the swap file's content as a
+// single undoable action.
+func (v *View) restoreSwap(file, swap string) {
+	if v.path != file {
+		return
+	}
+
+	v.Buffer.BeginUserAction()
+	defer v.Buffer.EndUserAction()
+
+	start, end := v.Buffer.Bounds()
+	v.Buffer.Delete(start, end)
+	v.Buffer.Insert(v.Buffer.StartIter(), swap)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".swap.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
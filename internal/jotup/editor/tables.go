@@ -0,0 +1,119 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/diamondburned/jotup/internal/jotup/editor/mdedit"
+)
+
+// maxTableLines is the number of lines around the cursor that are searched
+// for the rest of a table.
+const maxTableLines = 1000
+
+// tableAtCursor returns the offset and the block of the table around the
+// cursor. The table ends at lines without pipes and at code fences, and the
+// lines above its header aren't part of it. False is returned if the cursor
+// isn't in a table, or if it's in a fenced code block.
+func (v *View) tableAtCursor() (int, mdedit.Block, bool) {
+	cursor := v.Buffer.IterAtMark(v.Buffer.GetInsert())
+
+	start := cursor.Copy()
+	start.SetLineOffset(0)
+	end := cursor.Copy()
+	if !end.EndsLine() {
+		end.ForwardToLineEnd()
+	}
+
+	if !isTableLine(v.Buffer.Text(start, end, true)) {
+		return 0, mdedit.Block{}, false
+	}
+	if v.inCodeBlock(start) {
+		return 0, mdedit.Block{}, false
+	}
+
+	for i := 0; i < maxTableLines; i++ {
+		prev := start.Copy()
+		if !prev.BackwardLine() {
+			break
+		}
+		if !isTableLine(v.Buffer.Text(prev, start, true)) {
+			break
+		}
+		start = prev
+	}
+
+	for i := 0; i < maxTableLines; i++ {
+		next := end.Copy()
+		if !next.ForwardLine() {
+			break
+		}
+		if !next.EndsLine() {
+			next.ForwardToLineEnd()
+		}
+		if !isTableLine(v.Buffer.Text(end, next, true)) {
+			break
+		}
+		end = next
+	}
+
+	base := start.Offset()
+	offset, table, ok := mdedit.TableAt(mdedit.Block{
+		Text:     v.Buffer.Text(start, end, true),
+		SelStart: cursor.Offset() - base,
+		SelEnd:   cursor.Offset() - base,
+	})
+	return base + offset, table, ok
+}
+
+// isTableLine returns true if the line may be a table row.
+func isTableLine(line string) bool {
+	return strings.Contains(line, "|") && !mdedit.IsFence(strings.Trim(line, "\n"))
+}
+
+// editTable applies the table edit to the table at the cursor. False is
+// returned if the cursor isn't in a table.
+func (v *View) editTable(edit func(mdedit.Block) (mdedit.Block, bool)) bool {
+	if !v.Source.Editable() {
+		return false
+	}
+
+	base, in, ok := v.tableAtCursor()
+	if !ok {
+		return false
+	}
+
+	out, ok := edit(in)
+	if !ok {
+		return false
+	}
+
+	v.applyBlock(base, in, out)
+	return true
+}
+
+// moveTableCell aligns the table at the cursor and moves to the next cell, or
+// to the previous one. False is returned if the cursor isn't in a table.
+func (v *View) moveTableCell(backward bool) bool {
+	if v.Buffer.HasSelection() && !v.selectionInLine() {
+		return false
+	}
+
+	return v.editTable(func(b mdedit.Block) (mdedit.Block, bool) {
+		return mdedit.NextCell(b, backward)
+	})
+}
+
+// selectionInLine returns true if the selection doesn't span lines, like the
+// cell content that moving between cells selects.
+func (v *View) selectionInLine() bool {
+	start, end, _ := v.Buffer.SelectionBounds()
+	return start.Line() == end.Line()
+}
+
+func tableEdit(edit func(mdedit.Block) (mdedit.Block, bool)) func(*View) {
+	return func(v *View) {
+		if !v.editTable(edit) {
+			v.Toast.Show("The cursor isn't in a table.")
+		}
+	}
+}
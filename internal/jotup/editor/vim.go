@@ -0,0 +1,210 @@
+package editor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/extern/gtksourcex"
+)
+
+var vimMode = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Vim Mode",
+	Section:     "Editor",
+	Description: "Use Vim keybindings for modal editing. Needs GtkSourceView 5.4 or later.",
+})
+
+var vimCSS = cssutil.Applier("editor-vim", `
+	.editor-vim {
+		padding: 2px 8px;
+		background-color: @theme_base_color;
+		border-top: 1px solid @borders;
+		font-family: monospace;
+	}
+`)
+
+// vimState is the Vim emulation of a View. It's only active while keys is not
+// nil.
+type vimState struct {
+	im   *gtk.IMContext
+	keys *gtk.EventControllerKey
+
+	bar     *gtk.Box
+	command *gtk.Label // the command bar, which also shows the mode
+	pending *gtk.Label // the typed keys of an unfinished command
+}
+
+func (v *View) bindVim() {
+	v.vim.command = gtk.NewLabel("")
+	v.vim.command.SetXAlign(0)
+	v.vim.command.SetHExpand(true)
+
+	v.vim.pending = gtk.NewLabel("")
+	v.vim.pending.AddCSSClass("dim-label")
+
+	v.vim.bar = gtk.NewBox(gtk.OrientationHorizontal, 8)
+	v.vim.bar.SetVAlign(gtk.AlignEnd)
+	v.vim.bar.SetCanTarget(false)
+	v.vim.bar.Append(v.vim.command)
+	v.vim.bar.Append(v.vim.pending)
+	v.vim.bar.Hide()
+	vimCSS(v.vim.bar)
+
+	v.Overlay.AddOverlay(v.vim.bar)
+
+	vimMode.SubscribeWidget(v.Source, func() {
+		v.setVim(vimMode.Value())
+	})
+}
+
+// vimEnabled returns true if Vim mode is on.
+func (v *View) vimEnabled() bool {
+	return v.vim.keys != nil
+}
+
+func (v *View) setVim(enable bool) {
+	if enable == v.vimEnabled() {
+		return
+	}
+
+	if !enable {
+		v.Source.RemoveController(v.vim.keys)
+		v.vim.im.SetClientWidget(nil)
+		v.vim.im = nil
+		v.vim.keys = nil
+		v.vim.bar.Hide()
+
+		// Normal mode shows a block cursor.
+		v.Source.SetOverwrite(false)
+		return
+	}
+
+	v.vim.im = gtksourcex.NewVimIMContext()
+	if v.vim.im == nil {
+		v.Toast.Show("Vim mode needs GtkSourceView 5.4 or later.")
+		return
+	}
+
+	v.vim.im.SetClientWidget(v.Source)
+	v.vim.im.NotifyProperty("command-bar-text", v.updateVimBar)
+	v.vim.im.NotifyProperty("command-text", v.updateVimBar)
+	v.vim.im.Connect("execute-command", func(_ *gtk.IMContext, command string) bool {
+		return v.execVim(command)
+	})
+
+	v.vim.keys = gtk.NewEventControllerKey()
+	v.vim.keys.SetIMContext(v.vim.im)
+	v.vim.keys.SetPropagationPhase(gtk.PhaseCapture)
+	v.Source.AddController(v.vim.keys)
+
+	v.updateVimBar()
+	v.vim.bar.Show()
+}
+
+func (v *View) updateVimBar() {
+	command, _ := v.vim.im.ObjectProperty("command-bar-text").(string)
+	pending, _ := v.vim.im.ObjectProperty("command-text").(string)
+
+	v.vim.command.SetText(command)
+	v.vim.pending.SetText(pending)
+}
+
+// execVim runs the Ex commands that need the rest of the application. False
+// is returned for other commands, which Vim handles itself.
+func (v *View) execVim(command string) bool {
+	command = strings.TrimSpace(strings.TrimPrefix(command, ":"))
+
+	name, arg := command, ""
+	if i := strings.IndexByte(command, ' '); i >= 0 {
+		name, arg = command[:i], strings.TrimSpace(command[i+1:])
+	}
+
+	switch name {
+	case "w", "write", "w!", "write!":
+		if arg != "" {
+			v.writeCopy(v.ctrl.ResolvePath(arg), strings.HasSuffix(name, "!"))
+			return true
+		}
+		v.Save()
+		return true
+	case "q", "quit":
+		v.quitVim()
+		return true
+	case "wq", "x", "xit", "exit":
+		if arg != "" {
+			return false
+		}
+		// :x only writes if there are changes.
+		if name != "wq" && !v.unsaved {
+			v.quitVim()
+			return true
+		}
+		v.save(func(err error) {
+			if err == nil {
+				v.quitVim()
+			}
+		})
+		return true
+	case "e", "edit":
+		if arg == "" {
+			return false
+		}
+		v.ctrl.OpenPath(arg)
+		return true
+	}
+
+	return false
+}
+
+// quitVim closes the window. It asks about unsaved files on closing.
+func (v *View) quitVim() {
+	app.GTKWindowFromContext(v.ctx).Close()
+}
+
+// writeCopy asynchronously writes the buffer to another file, like Vim's
+// :w {file}. An existing file is only overwritten if force is true.
+func (v *View) writeCopy(path string, force bool) {
+	start, end := v.Buffer.Bounds()
+	text := v.Buffer.Text(start, end, true)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+
+	gtkutil.Async(v.ctx, func() func() {
+		err := writeFile(path, flags, text)
+
+		return func() {
+			switch {
+			case errors.Is(err, fs.ErrExist):
+				v.Toast.Show("The file already exists. Use :w! to overwrite it.")
+			case err != nil:
+				v.Toast.Show("Error: " + err.Error())
+			default:
+				v.Toast.Show("Written to " + filepath.Base(path) + ".")
+			}
+		}
+	})
+}
+
+func writeFile(path string, flags int, text string) error {
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,410 @@
+// Package export converts Markdown notes into standalone HTML documents. It
+// does not require GTK, so it can be used headlessly.
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/diamondburned/jotup/internal/extern/js/katex"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/chromastyle"
+	"github.com/diamondburned/jotup/internal/jotup/editor/md/mathext"
+	"github.com/pkg/errors"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Options describes the options for an Exporter.
+type Options struct {
+	// Style is the name of the chroma style used for code blocks. If empty,
+	// then chromastyle.DefaultLightStyle is used.
+	Style string
+	// StyleDir is the directory of the user's JSON chroma styles. If empty,
+	// then only the bundled styles are found.
+	StyleDir string
+	// Math is the KaTeX module used to render math into MathML. If nil, then
+	// math is written as TeX inside <code> elements.
+	Math *katex.Module
+	// Progress is called with the source and output paths of each Markdown
+	// file that ConvertDir converts, before converting it. It may be nil.
+	Progress func(src, dst string)
+}
+
+// Exporter converts Markdown into standalone HTML. An Exporter must not be
+// used concurrently, since the KaTeX module isn't thread-safe.
+type Exporter struct {
+	md       goldmark.Markdown
+	css      string
+	progress func(src, dst string)
+}
+
+// NewExporter creates a new Exporter.
+func NewExporter(opts Options) (*Exporter, error) {
+	if opts.Style == "" {
+		opts.Style = chromastyle.DefaultLightStyle
+	}
+
+	style, err := chromastyle.Find(opts.StyleDir, opts.Style)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot find code style")
+	}
+
+	r := nodeRenderer{
+		style: style,
+		math:  opts.Math,
+		code: chromahtml.New(
+			chromahtml.WithClasses(true),
+			chromahtml.TabWidth(4),
+		),
+	}
+
+	var css strings.Builder
+	css.WriteString(baseCSS)
+	if err := r.code.WriteCSS(&css, style); err != nil {
+		return nil, errors.Wrap(err, "cannot write code style CSS")
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM, mathext.Extension),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+			parser.WithASTTransformers(util.Prioritized(linkTransformer{}, 100)),
+		),
+		goldmark.WithRendererOptions(
+			goldmarkhtml.WithUnsafe(),
+			renderer.WithNodeRenderers(util.Prioritized(r, 200)),
+		),
+	)
+
+	return &Exporter{
+		md:       md,
+		css:      css.String(),
+		progress: opts.Progress,
+	}, nil
+}
+
+const baseCSS = `
+body {
+	margin: 0 auto;
+	padding: 1em;
+	max-width: 50em;
+	line-height: 1.5;
+	font-family: sans-serif;
+}
+pre, code {
+	font-family: monospace;
+}
+pre {
+	padding: 0.5em;
+	overflow-x: auto;
+}
+blockquote {
+	margin-left: 0;
+	padding-left: 1em;
+	border-left: 3px solid #ccc;
+	color: #555;
+}
+table {
+	border-collapse: collapse;
+}
+th, td {
+	padding: 0.25em 0.5em;
+	border: 1px solid #ccc;
+}
+img {
+	max-width: 100%;
+}
+.math-display {
+	overflow-x: auto;
+	text-align: center;
+}
+`
+
+// Render renders the given Markdown source into a standalone HTML document. If
+// title is empty, then the first heading is used.
+func (e *Exporter) Render(w io.Writer, title string, src []byte) error {
+	doc := e.md.Parser().Parse(text.NewReader(src))
+
+	if title == "" {
+		title = firstHeading(doc, src)
+	}
+
+	var body bytes.Buffer
+	if err := e.md.Renderer().Render(&body, src, doc); err != nil {
+		return errors.Wrap(err, "cannot render Markdown")
+	}
+
+	_, err := fmt.Fprintf(w, htmlDocument, html.EscapeString(title), e.css, body.Bytes())
+	return err
+}
+
+const htmlDocument = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="Jotup">
+<title>%s</title>
+<style>%s</style>
+</head>
+<body>
+<article>
+%s</article>
+</body>
+</html>
+`
+
+func firstHeading(doc ast.Node, src []byte) string {
+	var title string
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if h, ok := n.(*ast.Heading); ok && entering {
+			title = string(h.Text(src))
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return title
+}
+
+// ConvertFile converts the Markdown file at src into an HTML file at dst. The
+// parent directories of dst are created if needed.
+func (e *Exporter) ConvertFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if err := e.Render(&out, "", b); err != nil {
+		return errors.Wrapf(err, "cannot render %s", src)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return errors.Wrap(err, "cannot make output directory")
+	}
+
+	return os.WriteFile(dst, out.Bytes(), 0644)
+}
+
+// ConvertDir converts all Markdown files within the src directory into HTML
+// files within the dst directory, keeping the same directory structure. Other
+// files, such as images, are copied over as-is. Hidden files and directories
+// are skipped.
+func (e *Exporter) ConvertDir(src, dst string) error {
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+
+	// The output is compared as an absolute path, since only one of src and dst
+	// may be relative.
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			// Don't recurse into the output if it's within the input.
+			if abs, err := filepath.Abs(path); err == nil && abs == absDst {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		if IsMarkdown(path) {
+			out := filepath.Join(dst, HTMLPath(rel))
+			if e.progress != nil {
+				e.progress(path, out)
+			}
+			return e.ConvertFile(path, out)
+		}
+
+		return copyFile(path, filepath.Join(dst, rel))
+	})
+}
+
+// IsMarkdown returns true if the path has a Markdown file extension.
+func IsMarkdown(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	default:
+		return false
+	}
+}
+
+// HTMLPath replaces the path's Markdown extension with .html.
+func HTMLPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".html"
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return errors.Wrap(err, "cannot make output directory")
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return errors.Wrapf(err, "cannot copy %s", src)
+	}
+
+	return out.Close()
+}
+
+// linkTransformer rewrites relative links to Markdown files to point to the
+// exported HTML files instead.
+type linkTransformer struct{}
+
+func (linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if link, ok := n.(*ast.Link); ok && entering {
+			link.Destination = rewriteLink(link.Destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+func rewriteLink(dest []byte) []byte {
+	str := string(dest)
+	if strings.Contains(str, "://") || strings.HasPrefix(str, "#") || strings.HasPrefix(str, "mailto:") {
+		return dest
+	}
+
+	path, fragment := str, ""
+	if i := strings.IndexByte(str, '#'); i > -1 {
+		path, fragment = str[:i], str[i:]
+	}
+
+	if !IsMarkdown(path) {
+		return dest
+	}
+
+	return []byte(HTMLPath(path) + fragment)
+}
+
+// nodeRenderer renders code blocks with chroma and math with KaTeX.
+type nodeRenderer struct {
+	style *chroma.Style
+	code  *chromahtml.Formatter
+	math  *katex.Module
+}
+
+func (r nodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+	reg.Register(ast.KindCodeBlock, r.renderCodeBlock)
+	reg.Register(mathext.KindInlineMath, r.renderInlineMath)
+	reg.Register(mathext.KindBlockMath, r.renderBlockMath)
+}
+
+func (r nodeRenderer) renderCodeBlock(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	var code strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(src))
+	}
+
+	var lexer chroma.Lexer
+	if fenced, ok := n.(*ast.FencedCodeBlock); ok {
+		if lang := fenced.Language(src); lang != nil {
+			lexer = lexers.Get(string(lang))
+		}
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(code.String())
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+
+	iter, err := chroma.Coalesce(lexer).Tokenise(nil, code.String())
+	if err != nil {
+		// Not a big deal; just write the plain code.
+		w.WriteString("<pre><code>")
+		w.WriteString(html.EscapeString(code.String()))
+		w.WriteString("</code></pre>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	return ast.WalkSkipChildren, r.code.Format(w, r.style, iter)
+}
+
+func (r nodeRenderer) renderInlineMath(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		tex := string(n.(*mathext.InlineMath).TeX(src))
+		r.writeMath(w, tex, false)
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r nodeRenderer) renderBlockMath(w util.BufWriter, src []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		tex := string(n.(*mathext.BlockMath).TeX(src))
+		w.WriteString(`<div class="math-display">`)
+		r.writeMath(w, tex, true)
+		w.WriteString("</div>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+func (r nodeRenderer) writeMath(w util.BufWriter, tex string, display bool) {
+	if r.math != nil {
+		mathml, err := r.math.Render(tex, display)
+		if err == nil {
+			w.WriteString(mathml)
+			return
+		}
+		log.Printf("export: cannot render math %q: %v", tex, err)
+	}
+
+	w.WriteString(`<code class="math">`)
+	w.WriteString(html.EscapeString(tex))
+	w.WriteString(`</code>`)
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRewriteLink(t *testing.T) {
+	tests := []struct {
+		dest string
+		want string
+	}{
+		{"notes.md", "notes.html"},
+		{"sub/notes.markdown", "sub/notes.html"},
+		{"../up.MD", "../up.html"},
+		{"notes.md#section", "notes.html#section"},
+		{"#section", "#section"},
+		{"image.png", "image.png"},
+		{"https://example.com/readme.md", "https://example.com/readme.md"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := string(rewriteLink([]byte(test.dest))); got != test.want {
+			t.Errorf("rewriteLink(%q) = %q, want %q", test.dest, got, test.want)
+		}
+	}
+}
+
+func TestConvertDir(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"index.md":          "# Index\n\nSee [notes](sub/notes.md#top).\n",
+		"sub/notes.md":      "# Notes\n\n$x^2$\n",
+		"sub/image.png":     "not really a PNG",
+		".hidden/secret.md": "# Secret\n",
+		".hidden.md":        "# Hidden\n",
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(src, name), content)
+	}
+
+	// Export into the input folder, which must not be exported again.
+	dst := filepath.Join(src, "out")
+	writeFile(t, filepath.Join(dst, "stale.md"), "# Stale\n")
+
+	e, err := NewExporter(Options{})
+	if err != nil {
+		t.Fatal("cannot create exporter:", err)
+	}
+
+	if err := e.ConvertDir(src, dst); err != nil {
+		t.Fatal("cannot convert:", err)
+	}
+
+	want := []string{"index.html", "stale.md", "sub/image.png", "sub/notes.html"}
+	if got := listFiles(t, dst); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("exported files = %q, want %q", got, want)
+	}
+
+	index := readFile(t, filepath.Join(dst, "index.html"))
+	if !strings.Contains(index, `<title>Index</title>`) {
+		t.Error("index.html doesn't have the first heading as its title")
+	}
+	if !strings.Contains(index, `href="sub/notes.html#top"`) {
+		t.Error("index.html doesn't link to the exported notes")
+	}
+
+	// Math is kept as TeX without the KaTeX module.
+	notes := readFile(t, filepath.Join(dst, "sub", "notes.html"))
+	if !strings.Contains(notes, `<code class="math">x^2</code>`) {
+		t.Error("notes.html doesn't have the math as TeX")
+	}
+
+	if got := readFile(t, filepath.Join(dst, "sub", "image.png")); got != files["sub/image.png"] {
+		t.Errorf("image.png = %q, want it copied as-is", got)
+	}
+}
+
+func TestConvertDirRelative(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "notes.md"), "# Notes\n")
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(src); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	var converted []string
+	e, err := NewExporter(Options{
+		Progress: func(src, dst string) { converted = append(converted, dst) },
+	})
+	if err != nil {
+		t.Fatal("cannot create exporter:", err)
+	}
+
+	// The absolute input and the relative output are the same folder for the
+	// second export, which must skip the output of the first.
+	for i := 0; i < 2; i++ {
+		if err := e.ConvertDir(src, "out"); err != nil {
+			t.Fatal("cannot convert:", err)
+		}
+	}
+
+	want := []string{"notes.html"}
+	if got := listFiles(t, filepath.Join(src, "out")); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("exported files = %q, want %q", got, want)
+	}
+
+	out := filepath.Join("out", "notes.html")
+	if strings.Join(converted, " ") != out+" "+out {
+		t.Errorf("progress = %q, want %q twice", converted, out)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,184 @@
+package filetree
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// nameEdit is an inline edit of a row's name.
+type nameEdit struct {
+	done   func(name string)
+	cancel func()
+}
+
+// editName starts editing the name of the row at iter. done is called with the
+// new name once the user confirms it; otherwise, cancel is called.
+func (t *Tree) editName(iter *gtk.TreeIter, done func(string), cancel func()) {
+	t.cancelEdit()
+	t.editing = &nameEdit{done, cancel}
+
+	t.nameCell.SetObjectProperty("editable", true)
+	t.Scroll.View.SetCursor(t.root.store.Path(iter), t.cols[1], true)
+}
+
+func (t *Tree) finishEdit(name string) {
+	if e := t.stopEdit(); e != nil {
+		e.done(name)
+	}
+}
+
+func (t *Tree) cancelEdit() {
+	if e := t.stopEdit(); e != nil {
+		e.cancel()
+	}
+}
+
+func (t *Tree) stopEdit() *nameEdit {
+	e := t.editing
+	t.editing = nil
+	t.nameCell.SetObjectProperty("editable", false)
+	return e
+}
+
+// checkName trims the given file name and checks that it's a valid name for a
+// file in a directory. An empty name is returned as-is.
+func checkName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	return name, nil
+}
+
+func (t *Tree) newFile() { t.newEntry(false) }
+
+func (t *Tree) newFolder() { t.newEntry(true) }
+
+// newEntry inserts an editable row into the selected directory. The file is
+// only created once its name is confirmed.
+func (t *Tree) newEntry(isDir bool) {
+	t.cancelEdit()
+
+	dir := t.selectedDir()
+	dir.Init(t.ctx, func() {
+		dirIter, ok := dir.TreeIter()
+		if !ok {
+			return
+		}
+
+		t.Scroll.View.ExpandRow(dir.TreePath(), false)
+
+		icon := "text-x-generic-symbolic"
+		if isDir {
+			icon = "folder-symbolic"
+		}
+
+		iter := dir.store.Append(dirIter)
+		dir.store.Set(iter, allTreeColumns, []glib.Value{
+			*glib.NewValue(icon),
+			*glib.NewValue(""),
+			*glib.NewValue(""),
+			*glib.NewValue(""),
+			*glib.NewValue(true),
+		})
+
+		t.editName(iter,
+			func(name string) { t.createEntry(dir, iter, name, isDir) },
+			func() { dir.store.Remove(iter) },
+		)
+	})
+}
+
+// selectedDir returns the selected directory, the directory of the selected
+// file, or the root directory if nothing is selected.
+func (t *Tree) selectedDir() *TreeDir {
+	switch entry := t.selectedEntry().(type) {
+	case *TreeDir:
+		return entry
+	case *TreeFile:
+		return t.parentDir(entry.FilePath())
+	default:
+		return &t.root.TreeDir
+	}
+}
+
+// selectedEntry returns the first selected entry or nil if nothing or only the
+// root is selected.
+func (t *Tree) selectedEntry() TreeEntry {
+	_, paths := t.Scroll.View.Selection().SelectedRows()
+	if len(paths) == 0 {
+		return nil
+	}
+	return t.root.EntryFromTreePath(paths[0])
+}
+
+// parentDir returns the directory containing the given path.
+func (t *Tree) parentDir(path string) *TreeDir {
+	if dir, ok := t.root.Entry(filepath.Dir(path)).(*TreeDir); ok {
+		return dir
+	}
+	return &t.root.TreeDir
+}
+
+// createEntry creates the file or folder of the pending row on disk. Files are
+// opened once created.
+func (t *Tree) createEntry(dir *TreeDir, iter *gtk.TreeIter, name string, isDir bool) {
+	name, err := checkName(name)
+	if err != nil || name == "" {
+		dir.store.Remove(iter)
+		if err != nil {
+			app.Error(t.ctx, err)
+		}
+		return
+	}
+
+	path := filepath.Join(dir.FilePath(), name)
+	dir.store.SetValue(iter, columnName, glib.NewValue(html.EscapeString(name)))
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		info, err := createPath(path, isDir)
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				dir.store.Remove(iter)
+				app.Error(t.ctx, err)
+				return
+			}
+
+			if dir.add(iter, path, info) != nil && !isDir {
+				// Activate the new file so that it's opened.
+				t.SelectPath(path)
+			}
+		}
+	})
+}
+
+func createPath(path string, isDir bool) (fs.FileInfo, error) {
+	if isDir {
+		if err := os.Mkdir(path, 0755); err != nil {
+			return nil, err
+		}
+	} else {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return os.Stat(path)
+}
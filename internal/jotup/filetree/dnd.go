@@ -0,0 +1,192 @@
+package filetree
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/fileutil"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// bindDragDrop makes rows draggable onto directories to move them, and accepts
+// files dragged from other applications to copy them.
+func (t *Tree) bindDragDrop() {
+	drag := gtk.NewDragSource()
+	drag.SetActions(gdk.ActionCopy | gdk.ActionMove)
+	drag.ConnectPrepare(func(x, y float64) *gdk.ContentProvider {
+		bx, by := t.Scroll.View.ConvertWidgetToBinWindowCoords(int(x), int(y))
+		path, _, _, _, ok := t.Scroll.View.PathAtPos(bx, by)
+		if !ok {
+			return nil
+		}
+
+		entry := t.root.EntryFromTreePath(path)
+		if entry == nil {
+			return nil
+		}
+
+		t.dragging = entry.FilePath()
+		drag.SetIcon(t.Scroll.View.CreateRowDragIcon(path), 0, 0)
+
+		return gdk.NewContentProviderForValue(fileutil.FileValue(t.dragging))
+	})
+	drag.ConnectDragEnd(func(gdk.Dragger, bool) {
+		t.dragging = ""
+	})
+	t.Scroll.View.AddController(drag)
+
+	drop := gtk.NewDropTarget(gio.GTypeFile, gdk.ActionCopy|gdk.ActionMove)
+	drop.ConnectMotion(func(x, y float64) gdk.DragAction {
+		dir := t.dropDir(x, y)
+		t.Scroll.View.SetDragDestRow(dir.TreePath(), gtk.TreeViewDropIntoOrAfter)
+
+		if t.dragging != "" {
+			return gdk.ActionMove
+		}
+		return gdk.ActionCopy
+	})
+	drop.ConnectLeave(func() {
+		t.Scroll.View.SetDragDestRow(nil, gtk.TreeViewDropBefore)
+	})
+	drop.ConnectDrop(func(value coreglib.Value, x, y float64) bool {
+		t.Scroll.View.SetDragDestRow(nil, gtk.TreeViewDropBefore)
+
+		path, ok := fileutil.DroppedPath(&value)
+		if !ok {
+			return false
+		}
+
+		dir := t.dropDir(x, y).FilePath()
+		if path == t.dragging {
+			t.move(path, dir, true)
+		} else {
+			t.copyInto(path, dir)
+		}
+
+		return true
+	})
+	t.Scroll.View.AddController(drop)
+}
+
+// dropDir returns the directory that a drop at the given widget coordinates
+// goes into: the directory under the pointer, the directory of the file under
+// the pointer, or the root directory.
+func (t *Tree) dropDir(x, y float64) *TreeDir {
+	path, _, ok := t.Scroll.View.DestRowAtPos(int(x), int(y))
+	if !ok {
+		return &t.root.TreeDir
+	}
+
+	switch entry := t.root.EntryFromTreePath(path).(type) {
+	case *TreeDir:
+		return entry
+	case *TreeFile:
+		return t.parentDir(entry.FilePath())
+	default:
+		return &t.root.TreeDir
+	}
+}
+
+// move moves the file or folder at path into the directory dir. If undoable,
+// then a toast is shown to move it back.
+func (t *Tree) move(path, dir string, undoable bool) {
+	newPath := filepath.Join(dir, filepath.Base(path))
+	if newPath == path {
+		return
+	}
+
+	if IsWithin(dir, path) {
+		app.Error(t.ctx, fmt.Errorf("cannot move %q into itself", filepath.Base(path)))
+		return
+	}
+
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		info, err := renamePath(path, newPath)
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				app.Error(t.ctx, err)
+				return
+			}
+
+			t.moveEntry(path, newPath, info)
+
+			if undoable {
+				t.Toast.Show(
+					fmt.Sprintf("Moved %s to %s.", filepath.Base(path), t.dirName(dir)),
+					gtkutil.ActionData{Name: "Undo", Func: func() {
+						t.Toast.Dismiss()
+						t.move(newPath, filepath.Dir(path), false)
+					}},
+				)
+			}
+		}
+	})
+}
+
+// dirName returns the name of the directory to show to the user.
+func (t *Tree) dirName(dir string) string {
+	if dir == t.root.FilePath() {
+		return filepath.Base(dir)
+	}
+	return t.RelPath(dir)
+}
+
+// copyInto copies the file or folder at path into the directory dir. Existing
+// files are never overwritten.
+func (t *Tree) copyInto(path, dir string) {
+	dst := filepath.Join(dir, filepath.Base(path))
+	if IsWithin(dir, path) {
+		app.Error(t.ctx, fmt.Errorf("cannot copy %q into itself", filepath.Base(path)))
+		return
+	}
+
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		info, err := copyNew(path, dst)
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				app.Error(t.ctx, err)
+				return
+			}
+
+			if d := t.dirAt(dir); d != nil {
+				d.add(nil, dst, info)
+			}
+		}
+	})
+}
+
+// copyNew is like copyPath, except it fails if dst already exists.
+func copyNew(src, dst string) (fs.FileInfo, error) {
+	_, err := os.Lstat(dst)
+	if err == nil {
+		return nil, &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := copyPath(src, dst); err != nil {
+		return nil, err
+	}
+
+	return os.Lstat(dst)
+}
@@ -0,0 +1,414 @@
+package filetree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+)
+
+func init() {
+	keymap.Register(
+		keymap.Binding{Action: "filetree.rename", Label: "Rename File", Accels: []string{"F2"}},
+		keymap.Binding{Action: "filetree.trash", Label: "Move File to Trash"},
+		keymap.Binding{Action: "filetree.duplicate", Label: "Duplicate File"},
+		keymap.Binding{Action: "filetree.copy-path", Label: "Copy File Path"},
+		keymap.Binding{Action: "filetree.open-folder", Label: "Open Containing Folder"},
+	)
+}
+
+// bindContextMenu binds the right-click menu and the file shortcuts.
+func (t *Tree) bindContextMenu() {
+	t.fileActions = map[string]func(){
+		"filetree.rename":      t.renameSelected,
+		"filetree.trash":       t.trashSelected,
+		"filetree.duplicate":   t.duplicateSelected,
+		"filetree.copy-path":   t.copySelectedPath,
+		"filetree.open-folder": t.openSelectedFolder,
+	}
+	gtkutil.BindActionMap(t.Scroll.View, t.fileActions)
+	keymap.BindActions(t.Scroll.View, t.fileActions)
+
+	click := gtk.NewGestureClick()
+	click.SetButton(gdk.BUTTON_SECONDARY)
+	click.ConnectPressed(func(_ int, x, y float64) {
+		bx, by := t.Scroll.View.ConvertWidgetToBinWindowCoords(int(x), int(y))
+		path, _, _, _, ok := t.Scroll.View.PathAtPos(bx, by)
+		if !ok || t.root.EntryFromTreePath(path) == nil {
+			return
+		}
+
+		sel := t.Scroll.View.Selection()
+		sel.UnselectAll()
+		sel.SelectPath(path)
+
+		popover := gtkutil.NewPopoverMenuCustom(t.Scroll.View, gtk.PosBottom, []gtkutil.PopoverMenuItem{
+			gtkutil.MenuItem("_Rename", "filetree.rename"),
+			gtkutil.MenuItem("_Duplicate", "filetree.duplicate"),
+			gtkutil.MenuItem("Move to _Trash", "filetree.trash"),
+			gtkutil.MenuSeparator(""),
+			gtkutil.MenuItem("_Copy Path", "filetree.copy-path"),
+			gtkutil.MenuItem("_Open Containing Folder", "filetree.open-folder"),
+		})
+
+		rect := gdk.NewRectangle(int(x), int(y), 1, 1)
+		popover.SetPointingTo(&rect)
+		gtkutil.PopupFinally(popover)
+	})
+	t.Scroll.View.AddController(click)
+}
+
+// ActionNames returns the names of the actions on the selected file. They're
+// bound to the tree view, so they must be activated on it.
+func (t *Tree) ActionNames() []string {
+	names := make([]string, 0, len(t.fileActions))
+	for name := range t.fileActions {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ConnectFileMoved connects f to be called after a file or folder is renamed
+// from within the tree.
+func (t *Tree) ConnectFileMoved(f func(oldPath, newPath string)) {
+	t.moved = append(t.moved, f)
+}
+
+// ConnectFileRemoved connects f to be called after a file or folder is moved to
+// the trash from within the tree.
+func (t *Tree) ConnectFileRemoved(f func(path string)) {
+	t.removed = append(t.removed, f)
+}
+
+// ConnectFilesChanged connects f to be called after files within the tree were
+// changed on disk, such as by saving them. Bursts of changes are coalesced.
+func (t *Tree) ConnectFilesChanged(f func()) {
+	t.changed = append(t.changed, f)
+}
+
+func (t *Tree) renameSelected() {
+	entry := t.selectedEntry()
+	if entry == nil {
+		return
+	}
+
+	iter, ok := entry.TreeIter()
+	if !ok {
+		return
+	}
+
+	t.editName(iter, func(name string) { t.rename(entry, name) }, func() {})
+}
+
+func (t *Tree) rename(entry TreeEntry, name string) {
+	name, err := checkName(name)
+	if err != nil {
+		app.Error(t.ctx, err)
+		return
+	}
+
+	oldPath := entry.FilePath()
+	if name == "" || name == filepath.Base(oldPath) {
+		return
+	}
+
+	newPath := filepath.Join(filepath.Dir(oldPath), name)
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		info, err := renamePath(oldPath, newPath)
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				app.Error(t.ctx, err)
+				return
+			}
+
+			t.moveEntry(oldPath, newPath, info)
+		}
+	})
+}
+
+// renamePath is like os.Rename, except it never overwrites an existing file.
+func renamePath(oldPath, newPath string) (fs.FileInfo, error) {
+	if _, err := os.Lstat(newPath); err == nil {
+		return nil, &fs.PathError{Op: "rename", Path: newPath, Err: fs.ErrExist}
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return nil, err
+	}
+
+	return os.Lstat(newPath)
+}
+
+// moveEntry updates the tree after the file or folder at oldPath was moved to
+// newPath on disk. The row is kept in place if it stays in the same directory.
+func (t *Tree) moveEntry(oldPath, newPath string, info fs.FileInfo) {
+	oldDir := t.dirAt(filepath.Dir(oldPath))
+	newDir := t.dirAt(filepath.Dir(newPath))
+
+	var iter *gtk.TreeIter
+	expanded := false
+
+	if oldDir != nil {
+		if entry := oldDir.child[filepath.Base(oldPath)]; entry != nil {
+			if dir, ok := entry.(*TreeDir); ok {
+				// The children still have their old paths, so drop them. They're
+				// listed again once the directory is expanded.
+				expanded = t.Scroll.View.RowExpanded(dir.TreePath())
+				dir.clearRows()
+			}
+
+			if oldDir == newDir {
+				iter, _ = entry.TreeIter()
+			} else {
+				entry.Remove()
+			}
+		}
+		delete(oldDir.child, filepath.Base(oldPath))
+	}
+
+	if newDir != nil {
+		moved := newDir.add(iter, newPath, info)
+		if expanded && moved != nil {
+			t.Scroll.View.ExpandRow(moved.TreePath(), false)
+		}
+	}
+
+	t.moveUnsaved(oldPath, newPath)
+	t.markAll()
+
+	for _, f := range t.moved {
+		f(oldPath, newPath)
+	}
+}
+
+// dirAt returns the directory at the given path or nil if it's not in the tree.
+func (t *Tree) dirAt(path string) *TreeDir {
+	if path == t.root.FilePath() {
+		return &t.root.TreeDir
+	}
+	dir, _ := t.root.Entry(path).(*TreeDir)
+	return dir
+}
+
+// moveUnsaved moves the unsaved marks of oldPath and everything below it to
+// newPath.
+func (t *Tree) moveUnsaved(oldPath, newPath string) {
+	oldRel := t.RelPath(oldPath)
+	newRel := t.RelPath(newPath)
+
+	for path := range t.unsaved {
+		if moved, ok := MovedPath(path, oldRel, newRel); ok {
+			delete(t.unsaved, path)
+			t.unsaved[moved] = struct{}{}
+		}
+	}
+}
+
+// MovedPath returns the new path of the given path after oldPath was moved to
+// newPath. False is returned if path is neither oldPath nor inside it.
+func MovedPath(path, oldPath, newPath string) (string, bool) {
+	if !IsWithin(path, oldPath) {
+		return "", false
+	}
+	return newPath + strings.TrimPrefix(path, oldPath), true
+}
+
+// IsWithin returns true if path is dir or is inside it.
+func IsWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+func (t *Tree) trashSelected() {
+	entry := t.selectedEntry()
+	if entry == nil {
+		return
+	}
+
+	path := entry.FilePath()
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		err := gio.NewFileForPath(path).Trash(t.ctx)
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				app.Error(t.ctx, err)
+				return
+			}
+
+			t.removeEntry(path)
+		}
+	})
+}
+
+// removeEntry removes the entry at the given path after it was removed from
+// disk.
+func (t *Tree) removeEntry(path string) {
+	entry := t.root.Entry(path)
+	if entry == nil {
+		return
+	}
+
+	entry.Remove()
+	delete(t.parentDir(path).child, filepath.Base(path))
+
+	rel := t.RelPath(path)
+	for unsaved := range t.unsaved {
+		if IsWithin(unsaved, rel) {
+			delete(t.unsaved, unsaved)
+		}
+	}
+	t.markAll()
+
+	for _, f := range t.removed {
+		f(path)
+	}
+}
+
+func (t *Tree) duplicateSelected() {
+	entry := t.selectedEntry()
+	if entry == nil {
+		return
+	}
+
+	src := entry.FilePath()
+	t.setBusy()
+
+	gtkutil.Async(t.ctx, func() func() {
+		dst, err := duplicatePath(src)
+		var info fs.FileInfo
+		if err == nil {
+			info, err = os.Lstat(dst)
+		}
+
+		return func() {
+			t.setDone()
+
+			if err != nil {
+				app.Error(t.ctx, err)
+				return
+			}
+
+			if dup := t.parentDir(src).add(nil, dst, info); dup != nil {
+				sel := t.Scroll.View.Selection()
+				sel.UnselectAll()
+				sel.SelectPath(dup.TreePath())
+			}
+		}
+	})
+}
+
+// duplicatePath copies the file or folder at src next to it as "name copy",
+// "name copy 2" and so on, whichever doesn't exist yet. The new path is
+// returned.
+func duplicatePath(src string) (string, error) {
+	dir, base := filepath.Split(src)
+
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if stem == "" {
+		// Dotfiles, such as .gitignore.
+		stem, ext = base, ""
+	}
+
+	for i := 1; ; i++ {
+		name := stem + " copy" + ext
+		if i > 1 {
+			name = fmt.Sprintf("%s copy %d%s", stem, i, ext)
+		}
+
+		dst := filepath.Join(dir, name)
+
+		_, err := os.Lstat(dst)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		return dst, copyPath(src, dst)
+	}
+}
+
+// copyPath recursively copies src to dst. Symlinks are copied as-is.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, strings.TrimPrefix(path, src))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func (t *Tree) copySelectedPath() {
+	if entry := t.selectedEntry(); entry != nil {
+		t.Scroll.View.Clipboard().SetText(entry.FilePath())
+	}
+}
+
+func (t *Tree) openSelectedFolder() {
+	entry := t.selectedEntry()
+	if entry == nil {
+		return
+	}
+
+	dir := gio.NewFileForPath(filepath.Dir(entry.FilePath()))
+	gtk.ShowURI(&app.WindowFromContext(t.ctx).Window, dir.URI(), gdk.CURRENT_TIME)
+}
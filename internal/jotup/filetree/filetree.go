@@ -3,10 +3,16 @@ package filetree
 import (
 	"context"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotk4/pkg/pango"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/components/toast"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
 )
 
 // Tree is a file tree.
@@ -17,13 +23,31 @@ type Tree struct {
 		View *gtk.TreeView
 	}
 	Actions *gtk.ActionBar
+	Toast   *toast.Toast
 
 	spinbox *gtk.Revealer
 	spinner *gtk.Spinner
 
-	ctx  context.Context
-	root treeRoot
-	cols []*gtk.TreeViewColumn // for convenience
+	ctx      context.Context
+	root     treeRoot
+	cols     []*gtk.TreeViewColumn // for convenience
+	nameCell *gtk.CellRendererText
+	unsaved  map[string]struct{}       // relative paths
+	status   map[string]vcs.FileStatus // relative paths
+	editing  *nameEdit
+	dragging string // path of the row being dragged
+
+	dirty       map[*TreeDir]struct{} // directories to refresh
+	dirtyHandle glib.SourceHandle
+	dirtyStatus bool
+	dirtyFiles  map[string]struct{} // files whose contents changed
+	filterPrefs filterPrefs         // last applied
+
+	fileActions map[string]func()
+
+	moved   []func(oldPath, newPath string)
+	removed []func(path string)
+	changed []func()
 }
 
 var loadingCSS = cssutil.Applier("filetree-loading", `
@@ -51,9 +75,17 @@ var boxCSS = cssutil.Applier("filetree-box", `
 // NewTree creates a new Tree.
 func NewTree(ctx context.Context) *Tree {
 	t := Tree{
-		ctx:  ctx,
-		cols: newTreeColumns(),
+		ctx:        ctx,
+		nameCell:   gtk.NewCellRendererText(),
+		unsaved:    make(map[string]struct{}),
+		dirty:      make(map[*TreeDir]struct{}),
+		dirtyFiles: make(map[string]struct{}),
+
+		filterPrefs: currentFilterPrefs(),
 	}
+	t.cols = newTreeColumns(t.nameCell)
+	t.nameCell.ConnectEdited(func(_, name string) { t.finishEdit(name) })
+	t.nameCell.ConnectEditingCanceled(t.cancelEdit)
 
 	t.Scroll.View = gtk.NewTreeView()
 	t.Scroll.View.AddCSSClass("filetree-view")
@@ -79,11 +111,24 @@ func NewTree(ctx context.Context) *Tree {
 			d.Init(t.ctx, func() {
 				// Re-expand the path after loading.
 				t.Scroll.View.ExpandToPath(d.TreePath())
+				t.markAll()
+				t.watch(d)
 				t.setDone()
 			})
 		}
 	})
 
+	t.Scroll.View.ConnectRowCollapsed(func(iter *gtk.TreeIter, path *gtk.TreePath) {
+		// The root can't be looked up, so it's never cleared.
+		d, ok := t.root.Entry(t.root.IterPath(iter)).(*TreeDir)
+		if ok {
+			d.collapse()
+		}
+	})
+
+	t.bindContextMenu()
+	t.bindDragDrop()
+
 	t.Scroll.ScrolledWindow = gtk.NewScrolledWindow()
 	t.Scroll.SetVExpand(true)
 	t.Scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
@@ -111,11 +156,30 @@ func NewTree(ctx context.Context) *Tree {
 	t.Actions.PackEnd(newFnButton("folder-new-symbolic", "New Folder", t.newFolder))
 	t.Actions.PackEnd(newFnButton("document-new-symbolic", "New File", t.newFile))
 
+	t.Toast = toast.NewToast(gtk.PackEnd)
+	t.Toast.SetTimeout(5 * time.Second)
+	t.Toast.SetVAlign(gtk.AlignEnd)
+
+	overlay := gtk.NewOverlay()
+	overlay.SetChild(t.Scroll)
+	overlay.AddOverlay(t.Toast)
+
 	t.Box = gtk.NewBox(gtk.OrientationVertical, 0)
-	t.Box.Append(t.Scroll)
+	t.Box.Append(overlay)
 	t.Box.Append(t.Actions)
 	boxCSS(t.Box)
 
+	onFilterChange := func() {
+		// Subscribing also calls this, so skip if nothing changed.
+		if current := currentFilterPrefs(); current != t.filterPrefs {
+			t.filterPrefs = current
+			t.refreshAll()
+		}
+	}
+	showHidden.SubscribeWidget(t, onFilterChange)
+	hideIgnored.SubscribeWidget(t, onFilterChange)
+	excludePatterns.SubscribeWidget(t, onFilterChange)
+
 	return &t
 }
 
@@ -123,8 +187,12 @@ func NewTree(ctx context.Context) *Tree {
 func (t *Tree) Refresh() {
 	t.SetSensitive(false)
 	t.setBusy()
+	t.root.filter.reset()
 	t.root.Refresh(t.ctx, func() {
 		t.Scroll.View.ExpandToPath(t.root.RootPath())
+		t.markAll()
+		t.watch(&t.root.TreeDir)
+		t.RefreshStatus()
 		t.setDone()
 		t.SetSensitive(true)
 	})
@@ -138,7 +206,10 @@ func (t *Tree) Load(path string) {
 	}
 
 	if t.root.filePath != path {
+		t.root.clearRows()
 		t.root = newTreeRoot(path)
+		t.unsaved = make(map[string]struct{})
+		t.status = nil
 		t.Scroll.View.SetModel(t.root.Model())
 	}
 
@@ -180,30 +251,78 @@ func (t *Tree) RelPath(path string) string {
 	return p
 }
 
-// SetUnsaved sets whether the path is saved or not.
+// SetUnsaved sets whether the file at the given path has unsaved changes. The
+// path may be absolute or relative to the root path. Directories containing
+// unsaved files are marked as well. Calling it multiple times with the same
+// arguments is fine.
 func (t *Tree) SetUnsaved(path string, unsaved bool) {
+	path = t.RelPath(path)
+
+	if unsaved {
+		t.unsaved[path] = struct{}{}
+	} else {
+		delete(t.unsaved, path)
+	}
+
+	t.markUnsaved(path)
+}
+
+// UnsavedPaths returns the sorted paths of all files marked as unsaved. The
+// paths are relative to the root path.
+func (t *Tree) UnsavedPaths() []string {
+	paths := make([]string, 0, len(t.unsaved))
+	for path := range t.unsaved {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// markUnsaved updates the unsaved dot of the entry at the given relative path
+// and of all its parent directories.
+func (t *Tree) markUnsaved(path string) {
 	t.root.WalkEntry(path, func(entry TreeEntry) bool {
 		switch entry := entry.(type) {
 		case *TreeDir:
-			// Keep track of the number of unsaved files in the directory as we
-			// traverse down.
-			if unsaved {
-				entry.unsaved++
-			} else {
-				entry.unsaved--
-			}
-			entry.SetUnsaved(entry.unsaved > 0)
+			entry.SetUnsaved(t.dirUnsaved(t.RelPath(entry.FilePath())))
 		case *TreeFile:
+			_, unsaved := t.unsaved[path]
 			entry.SetUnsaved(unsaved)
 		}
 		return true
 	})
 }
 
+// markAll reapplies the unsaved dots and the git status markers. It is called
+// after rows are (re)created.
+func (t *Tree) markAll() {
+	for path := range t.unsaved {
+		t.markUnsaved(path)
+	}
+	t.markAllStatus()
+}
+
+// dirUnsaved returns true if the directory at the given relative path contains
+// any unsaved file.
+func (t *Tree) dirUnsaved(dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for path := range t.unsaved {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // SelectPath expands the file tree to the directory containing the given path
 // and selects it. The activation signal is fired.
 func (t *Tree) SelectPath(path string) {
 	t.root.ResolveEntry(t.ctx, path, func(entry TreeEntry) bool {
+		if entry == nil {
+			// Hidden from the tree or gone.
+			return false
+		}
+
 		t.Scroll.View.ExpandToPath(entry.TreePath())
 
 		if !entry.IsDir() {
@@ -217,9 +336,29 @@ func (t *Tree) SelectPath(path string) {
 	})
 }
 
-func (t *Tree) newFile() {}
+// ExpandedPaths returns the paths of all expanded directories relative to the
+// root path.
+func (t *Tree) ExpandedPaths() []string {
+	var paths []string
+	t.Scroll.View.MapExpandedRows(func(_ *gtk.TreeView, path *gtk.TreePath) {
+		if entry := t.root.EntryFromTreePath(path); entry != nil {
+			paths = append(paths, t.RelPath(entry.FilePath()))
+		}
+	})
+	return paths
+}
 
-func (t *Tree) newFolder() {}
+// ExpandPath expands the directory at the given path and all its parents,
+// loading them as needed.
+func (t *Tree) ExpandPath(path string) {
+	t.root.ResolveEntry(t.ctx, path, func(entry TreeEntry) bool {
+		if entry == nil {
+			return false
+		}
+		t.Scroll.View.ExpandToPath(entry.TreePath())
+		return true
+	})
+}
 
 func (t *Tree) setBusy() {
 	// t.progress.Show()
@@ -233,7 +372,7 @@ func (t *Tree) setDone() {
 	t.spinbox.SetRevealChild(false)
 }
 
-func newTreeColumns() []*gtk.TreeViewColumn {
+func newTreeColumns(nameCell *gtk.CellRendererText) []*gtk.TreeViewColumn {
 	return []*gtk.TreeViewColumn{
 		func() *gtk.TreeViewColumn {
 			ren := gtk.NewCellRendererPixbuf()
@@ -249,7 +388,7 @@ func newTreeColumns() []*gtk.TreeViewColumn {
 			return col
 		}(),
 		func() *gtk.TreeViewColumn {
-			ren := gtk.NewCellRendererText()
+			ren := nameCell
 			ren.SetPadding(3, 4)
 			ren.SetObjectProperty("ellipsize", pango.EllipsizeMiddle)
 			ren.SetObjectProperty("ellipsize-set", true)
@@ -273,6 +412,18 @@ func newTreeColumns() []*gtk.TreeViewColumn {
 			col.AddAttribute(ren, "sensitive", int(columnSensitive))
 			col.SetSizing(gtk.TreeViewColumnAutosize)
 
+			return col
+		}(),
+		func() *gtk.TreeViewColumn {
+			ren := gtk.NewCellRendererText()
+			ren.SetPadding(3, 0)
+
+			col := gtk.NewTreeViewColumn()
+			col.PackStart(ren, false)
+			col.AddAttribute(ren, "markup", int(columnStatus))
+			col.AddAttribute(ren, "sensitive", int(columnSensitive))
+			col.SetSizing(gtk.TreeViewColumnAutosize)
+
 			return col
 		}(),
 	}
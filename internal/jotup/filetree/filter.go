@@ -0,0 +1,237 @@
+package filetree
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+var showHidden = prefs.NewBool(false, prefs.PropMeta{
+	Name:        "Show Hidden Files",
+	Section:     "Files",
+	Description: "Show files whose names start with a dot in the file tree.",
+})
+
+var hideIgnored = prefs.NewBool(true, prefs.PropMeta{
+	Name:        "Hide Ignored Files",
+	Section:     "Files",
+	Description: "Hide files matched by .gitignore and .ignore files in the file tree.",
+})
+
+var excludePatterns = prefs.NewString(".git", prefs.StringMeta{
+	Name:        "Excluded Files",
+	Section:     "Files",
+	Description: "Comma-separated gitignore patterns of files to always hide from the file tree.",
+	Placeholder: "node_modules, *.o",
+})
+
+// ignoreFiles are the files holding ignore patterns. Later ones take
+// precedence.
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// isIgnoreFile returns true if the file at the given path holds ignore
+// patterns.
+func isIgnoreFile(path string) bool {
+	name := filepath.Base(path)
+	for _, ignoreFile := range ignoreFiles {
+		if name == ignoreFile {
+			return true
+		}
+	}
+	return false
+}
+
+// filterPrefs is a snapshot of the preferences affecting fileFilter.
+type filterPrefs struct {
+	showHidden  bool
+	hideIgnored bool
+	exclude     string
+}
+
+func currentFilterPrefs() filterPrefs {
+	return filterPrefs{
+		showHidden:  showHidden.Value(),
+		hideIgnored: hideIgnored.Value(),
+		exclude:     excludePatterns.Value(),
+	}
+}
+
+// fileFilter decides which files are hidden from a tree. It is shared by all
+// directories of the tree and is safe to use concurrently.
+type fileFilter struct {
+	root string
+
+	mu      sync.Mutex
+	ignores map[string][]gitignore.Pattern // dir -> its own patterns
+	repo    *string                        // worktree containing root, "" if none
+}
+
+func newFileFilter(root string) *fileFilter {
+	return &fileFilter{
+		root:    root,
+		ignores: make(map[string][]gitignore.Pattern),
+	}
+}
+
+// hider returns a function that reports whether a file directly inside the
+// given directory is hidden. It may read ignore files, so it should be called
+// outside the main thread.
+func (f *fileFilter) hider(dir string) func(name string, isDir bool) bool {
+	opts := currentFilterPrefs()
+	domain := relParts(f.root, dir)
+
+	var excludes []gitignore.Pattern
+	for _, p := range strings.Split(opts.exclude, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			excludes = append(excludes, gitignore.ParsePattern(p, nil))
+		}
+	}
+	exclude := gitignore.NewMatcher(excludes)
+
+	var ignore gitignore.Matcher
+	var ignoreDomain []string
+
+	if opts.hideIgnored {
+		// Ignore files apply from the root of the repository, which may be
+		// above the opened folder.
+		var patterns []gitignore.Pattern
+
+		base := f.repoRoot()
+		if base != "" {
+			patterns = readIgnoreFile(filepath.Join(base, ".git", "info", "exclude"), nil)
+		} else {
+			base = f.root
+		}
+		ignoreDomain = relParts(base, dir)
+
+		// Patterns closer to the file take precedence.
+		for i := 0; i <= len(ignoreDomain); i++ {
+			patterns = append(patterns, f.dirPatterns(base, ignoreDomain[:i])...)
+		}
+
+		ignore = gitignore.NewMatcher(patterns)
+	}
+
+	return func(name string, isDir bool) bool {
+		if !opts.showHidden && strings.HasPrefix(name, ".") {
+			return true
+		}
+		// The excluded files are always hidden, regardless of negations in
+		// ignore files.
+		if exclude.Match(append(domain[:len(domain):len(domain)], name), isDir) {
+			return true
+		}
+		if ignore != nil {
+			return ignore.Match(append(ignoreDomain[:len(ignoreDomain):len(ignoreDomain)], name), isDir)
+		}
+		return false
+	}
+}
+
+// forget drops the cached ignore patterns of the given directory, so that its
+// ignore files are read again.
+func (f *fileFilter) forget(dir string) {
+	f.mu.Lock()
+	delete(f.ignores, filepath.Clean(dir))
+	f.mu.Unlock()
+}
+
+// reset drops all cached ignore patterns.
+func (f *fileFilter) reset() {
+	f.mu.Lock()
+	f.ignores = make(map[string][]gitignore.Pattern)
+	f.repo = nil
+	f.mu.Unlock()
+}
+
+// repoRoot returns the root of the git worktree containing the tree, or an
+// empty string if it's not in one.
+func (f *fileFilter) repoRoot() string {
+	f.mu.Lock()
+	repo := f.repo
+	f.mu.Unlock()
+
+	if repo != nil {
+		return *repo
+	}
+
+	var root string
+	for dir := f.root; ; {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			root = dir
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	f.mu.Lock()
+	f.repo = &root
+	f.mu.Unlock()
+
+	return root
+}
+
+// dirPatterns returns the patterns of the ignore files in the directory with
+// the given path parts relative to base.
+func (f *fileFilter) dirPatterns(base string, domain []string) []gitignore.Pattern {
+	dir := filepath.Join(append([]string{base}, domain...)...)
+
+	f.mu.Lock()
+	patterns, ok := f.ignores[dir]
+	f.mu.Unlock()
+
+	if ok {
+		return patterns
+	}
+
+	for _, ignoreFile := range ignoreFiles {
+		patterns = append(patterns, readIgnoreFile(filepath.Join(dir, ignoreFile), domain)...)
+	}
+
+	f.mu.Lock()
+	f.ignores[dir] = patterns
+	f.mu.Unlock()
+
+	return patterns
+}
+
+// relParts returns the parts of the path relative to base, or nil for base itself.
+func relParts(base, path string) []string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == "." {
+		return nil
+	}
+	return strings.Split(rel, string(filepath.Separator))
+}
+
+// readIgnoreFile reads the patterns of the given ignore file. A missing or
+// unreadable file has no patterns.
+func readIgnoreFile(path string, domain []string) []gitignore.Pattern {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") && strings.TrimSpace(line) != "" {
+			patterns = append(patterns, gitignore.ParsePattern(line, domain))
+		}
+	}
+
+	return patterns
+}
@@ -0,0 +1,199 @@
+package filetree
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
+	"github.com/go-git/go-git/v5"
+)
+
+// RefreshStatus asynchronously refreshes the git status markers.
+func (t *Tree) RefreshStatus() {
+	root := t.Path()
+	if root == "" {
+		return
+	}
+
+	gtkutil.Async(t.ctx, func() func() {
+		status, err := gitStatus(root)
+		if err != nil {
+			log.Println("cannot get git status:", err)
+		}
+
+		return func() {
+			// Make sure we're still on the same folder.
+			if t.Path() != root {
+				return
+			}
+
+			t.status = status
+			t.markAllStatus()
+		}
+	})
+}
+
+// refreshFileStatus asynchronously refreshes the git status markers of the
+// given files and their parent directories.
+func (t *Tree) refreshFileStatus(paths []string) {
+	root := t.Path()
+	if root == "" {
+		return
+	}
+
+	gtkutil.Async(t.ctx, func() func() {
+		status, err := gitFileStatus(root, paths)
+		if err != nil {
+			log.Println("cannot get git status:", err)
+			return nil
+		}
+
+		return func() {
+			if t.Path() != root || status == nil {
+				return
+			}
+
+			if t.status == nil {
+				t.status = make(map[string]vcs.FileStatus)
+			}
+
+			for rel, s := range status {
+				if s == vcs.Unmodified {
+					delete(t.status, rel)
+				} else {
+					t.status[rel] = s
+				}
+
+				for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+					t.updateDirStatus(dir)
+				}
+			}
+
+			t.markAllStatus()
+		}
+	})
+}
+
+// updateDirStatus recomputes the status of the directory with the given
+// relative path from the statuses below it.
+func (t *Tree) updateDirStatus(dir string) {
+	var status vcs.FileStatus
+
+	prefix := dir + string(filepath.Separator)
+	for rel, s := range t.status {
+		if strings.HasPrefix(rel, prefix) && s > status {
+			status = s
+		}
+	}
+
+	if status == vcs.Unmodified {
+		delete(t.status, dir)
+	} else {
+		t.status[dir] = status
+	}
+}
+
+// gitFileStatus is like gitStatus, except only the given files are checked.
+// Unmodified files are included.
+func gitFileStatus(root string, paths []string) (map[string]vcs.FileStatus, error) {
+	repo, err := vcs.Open(root)
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	status := make(map[string]vcs.FileStatus, len(paths))
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		s, err := repo.PathStatus(path)
+		if err != nil {
+			return nil, err
+		}
+
+		status[rel] = s
+	}
+
+	return status, nil
+}
+
+// gitStatus returns the git status of the files within root, keyed by their
+// relative paths. Directories have the greatest status of their files.
+func gitStatus(root string) (map[string]vcs.FileStatus, error) {
+	repo, err := vcs.Open(root)
+	if err != nil {
+		if errors.Is(err, git.ErrRepositoryNotExists) {
+			err = nil
+		}
+		return nil, err
+	}
+
+	files, err := repo.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]vcs.FileStatus, len(files))
+
+	for path, s := range files {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		status[rel] = s
+
+		for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+			if status[dir] < s {
+				status[dir] = s
+			}
+		}
+	}
+
+	return status, nil
+}
+
+// markAllStatus reapplies the git status markers of all listed entries.
+func (t *Tree) markAllStatus() {
+	for _, dir := range t.root.initializedDirs() {
+		for _, entry := range dir.child {
+			var status vcs.FileStatus
+			if t.status != nil {
+				status = t.status[t.RelPath(entry.FilePath())]
+			}
+
+			switch entry := entry.(type) {
+			case *TreeDir:
+				entry.SetStatus(status)
+			case *TreeFile:
+				entry.SetStatus(status)
+			}
+		}
+	}
+}
+
+// SetStatus sets the git status marker shown next to the file.
+func (f *TreeFile) SetStatus(status vcs.FileStatus) {
+	iter, ok := f.TreeIter()
+	if !ok {
+		return
+	}
+
+	var markup string
+	if marker := status.Marker(); marker != "" {
+		markup = fmt.Sprintf(`<span color="%s"><b>%s</b></span>`, status.Color(), marker)
+	}
+
+	f.store.SetValue(iter, columnStatus, glib.NewValue(markup))
+}
@@ -0,0 +1,67 @@
+package filetree
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// maxIndexedFiles caps the number of files listed by IndexFiles, in case a huge
+// folder is opened by accident.
+const maxIndexedFiles = 100000
+
+// IndexFiles asynchronously lists all files below the root that aren't hidden
+// from the tree, then calls done with their sorted absolute paths. done isn't
+// called if another folder was loaded in the meantime.
+func (t *Tree) IndexFiles(done func(paths []string)) {
+	root := t.Path()
+	if root == "" {
+		done(nil)
+		return
+	}
+
+	filter := t.root.filter
+
+	gtkutil.Async(t.ctx, func() func() {
+		var paths []string
+		indexDir(filter, root, &paths)
+		sort.Strings(paths)
+
+		return func() {
+			if t.Path() == root {
+				done(paths)
+			}
+		}
+	})
+}
+
+func indexDir(filter *fileFilter, dir string, paths *[]string) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Println("cannot index directory:", err)
+		return
+	}
+
+	hidden := filter.hider(dir)
+
+	for _, file := range files {
+		if len(*paths) >= maxIndexedFiles {
+			return
+		}
+
+		name := file.Name()
+		if hidden(name, file.IsDir()) {
+			continue
+		}
+
+		// Symlinks aren't followed, so that cycles are impossible.
+		if file.IsDir() {
+			indexDir(filter, filepath.Join(dir, name), paths)
+		} else {
+			*paths = append(*paths, filepath.Join(dir, name))
+		}
+	}
+}
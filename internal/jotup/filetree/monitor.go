@@ -0,0 +1,164 @@
+package filetree
+
+import (
+	"log"
+
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+)
+
+// refreshDelay is how long in milliseconds directory listings and git statuses
+// are held back after a change. A checkout or a build can touch many entries at
+// once, and they are all picked up by a single refresh.
+const refreshDelay = 250
+
+// watch starts monitoring the directory for changes made by other programs.
+// Nothing is done if it's already monitored.
+func (t *Tree) watch(d *TreeDir) {
+	if d.monitor != nil {
+		return
+	}
+
+	m, err := gio.NewFileForPath(d.filePath).MonitorDirectory(t.ctx, gio.FileMonitorNone)
+	if err != nil {
+		log.Println("cannot monitor directory:", err)
+		return
+	}
+
+	d.monitor = gio.BaseFileMonitor(m)
+	d.monitor.ConnectChanged(func(file, _ gio.Filer, event gio.FileMonitorEvent) {
+		if isIgnoreFile(file.Path()) && event != gio.FileMonitorEventChanged {
+			// The ignore patterns apply to everything below.
+			d.filter.forget(d.filePath)
+			for _, dir := range d.initializedDirs() {
+				t.queueRefresh(dir)
+			}
+			return
+		}
+
+		switch event {
+		case gio.FileMonitorEventChangesDoneHint:
+			// The listing only changes when files come and go, but the git
+			// status of the file may change with its contents.
+			t.queueFileStatus(file.Path())
+			return
+		case gio.FileMonitorEventChanged, gio.FileMonitorEventAttributeChanged:
+			return
+		}
+		t.queueRefresh(d)
+	})
+}
+
+// queueRefresh refreshes the directory once the current burst of events is
+// over. Directories queued in the meantime are refreshed together.
+func (t *Tree) queueRefresh(d *TreeDir) {
+	t.dirty[d] = struct{}{}
+	t.queueStatus()
+}
+
+// queueStatus refreshes the git status once the current burst of events is
+// over.
+func (t *Tree) queueStatus() {
+	t.dirtyStatus = true
+	t.queueDirty()
+}
+
+// queueFileStatus is like queueStatus, except only the git status of the given
+// file is refreshed. Walking the whole worktree on every save is too slow for
+// large repositories.
+func (t *Tree) queueFileStatus(path string) {
+	t.dirtyFiles[path] = struct{}{}
+	t.queueDirty()
+}
+
+func (t *Tree) queueDirty() {
+	if t.dirtyHandle > 0 {
+		glib.SourceRemove(t.dirtyHandle)
+	}
+	t.dirtyHandle = glib.TimeoutAdd(refreshDelay, func() {
+		t.dirtyHandle = 0
+		t.refreshDirty()
+	})
+}
+
+func (t *Tree) refreshDirty() {
+	for _, f := range t.changed {
+		f()
+	}
+
+	switch {
+	case t.dirtyStatus:
+		t.dirtyStatus = false
+		t.RefreshStatus()
+	case len(t.dirtyFiles) > 0:
+		paths := make([]string, 0, len(t.dirtyFiles))
+		for path := range t.dirtyFiles {
+			paths = append(paths, path)
+		}
+		t.refreshFileStatus(paths)
+	}
+
+	for path := range t.dirtyFiles {
+		delete(t.dirtyFiles, path)
+	}
+
+	for d := range t.dirty {
+		delete(t.dirty, d)
+
+		// Skip directories that were collapsed or removed since.
+		if d.monitor == nil || d.TreePath() == nil {
+			continue
+		}
+
+		d.Refresh(t.ctx, t.markAll)
+	}
+}
+
+// refreshAll refreshes all listed directories, such as after the filter
+// preferences changed.
+func (t *Tree) refreshAll() {
+	if t.root.filePath == "" {
+		return
+	}
+
+	for _, dir := range t.root.initializedDirs() {
+		dir.Refresh(t.ctx, t.markAll)
+	}
+}
+
+// initializedDirs returns the directory and all directories below it that have
+// been listed.
+func (d *TreeDir) initializedDirs() []*TreeDir {
+	if d.child == nil {
+		return nil
+	}
+
+	dirs := []*TreeDir{d}
+	for _, entry := range d.child {
+		if dir, ok := entry.(*TreeDir); ok {
+			dirs = append(dirs, dir.initializedDirs()...)
+		}
+	}
+	return dirs
+}
+
+// unwatch stops monitoring the directory.
+func (d *TreeDir) unwatch() {
+	if d.monitor != nil {
+		d.monitor.Cancel()
+		d.monitor = nil
+	}
+}
+
+// collapse clears the directory after its row was collapsed, so that it's no
+// longer monitored. It's listed again once expanded.
+func (d *TreeDir) collapse() {
+	iter, ok := d.TreeIter()
+	if !ok {
+		return
+	}
+
+	d.clearRows()
+	d.child = nil
+	d.temp = d.store.Append(iter)
+}
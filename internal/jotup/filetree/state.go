@@ -10,9 +10,11 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
 	"github.com/diamondburned/gotk4/pkg/glib/v2"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/fileutil"
 )
 
 type treeColumn = int
@@ -23,7 +25,7 @@ const (
 	columnPath
 	columnUnsaved
 	columnSensitive
-	// TODO: add column for system attribute marks (e.g. errors, loading, etc.)
+	columnStatus // git status markup
 )
 
 var allTreeColumns = []treeColumn{
@@ -32,6 +34,7 @@ var allTreeColumns = []treeColumn{
 	columnPath,
 	columnUnsaved,
 	columnSensitive,
+	columnStatus,
 }
 
 var columnTypes = []glib.Type{
@@ -40,6 +43,7 @@ var columnTypes = []glib.Type{
 	glib.TypeString,
 	glib.TypeString,
 	glib.TypeBoolean,
+	glib.TypeString,
 }
 
 func filePseudoError(err error) []glib.Value {
@@ -52,6 +56,7 @@ func filePseudoError(err error) []glib.Value {
 		*glib.NewValue(""),
 		*glib.NewValue(""),
 		*glib.NewValue(false),
+		*glib.NewValue(""),
 	}
 }
 
@@ -66,7 +71,7 @@ func fileColumnValues(path string, file fs.DirEntry) []glib.Value {
 	case file.Type().Perm()&0111 != 0:
 		icon = "application-x-appliance-symbolic"
 	default:
-		icon = iconExt(filepath.Ext(file.Name()))
+		icon = fileutil.IconName(file.Name())
 	}
 
 	return []glib.Value{
@@ -75,6 +80,7 @@ func fileColumnValues(path string, file fs.DirEntry) []glib.Value {
 		*glib.NewValue(path),
 		*glib.NewValue(""),
 		*glib.NewValue(true),
+		*glib.NewValue(""),
 	}
 }
 
@@ -95,14 +101,14 @@ type TreeEntry interface {
 
 type TreeFile struct {
 	store    *gtk.TreeStore
-	treePath *gtk.TreePath
+	rowRef   *gtk.TreeRowReference // follows the row as siblings come and go
 	filePath string
 }
 
 func newTreeFile(store *gtk.TreeStore, root *gtk.TreeIter, path string) *TreeFile {
 	return &TreeFile{
 		store:    store,
-		treePath: store.Path(root),
+		rowRef:   gtk.NewTreeRowReference(store, store.Path(root)),
 		filePath: path,
 	}
 }
@@ -123,14 +129,19 @@ func (f *TreeFile) FilePath() string {
 	return f.filePath
 }
 
-// TreePath returns the file's TreePath.
+// TreePath returns the file's TreePath. It returns nil if the row has been
+// removed.
 func (f *TreeFile) TreePath() *gtk.TreePath {
-	return f.treePath
+	return f.rowRef.Path()
 }
 
 // TreeIter returns f's TreeIter.
 func (f *TreeFile) TreeIter() (*gtk.TreeIter, bool) {
-	return f.store.Iter(f.treePath)
+	path := f.rowRef.Path()
+	if path == nil {
+		return nil, false
+	}
+	return f.store.Iter(path)
 }
 
 // Remove removes f from the store.
@@ -164,20 +175,29 @@ type TreeDir struct {
 	temp *gtk.TreeIter
 	// load is nilable.
 	load chan struct{}
-
-	unsaved int
+	// monitor is nilable.
+	monitor *gio.FileMonitor
+	filter  *fileFilter
 }
 
-func newTreeDir(store *gtk.TreeStore, root *gtk.TreeIter, path string) *TreeDir {
+func newTreeDir(store *gtk.TreeStore, filter *fileFilter, root *gtk.TreeIter, path string) *TreeDir {
 	dir := TreeDir{
 		TreeFile: *newTreeFile(store, root, path),
 		temp:     store.Append(root),
+		filter:   filter,
 	}
 	return &dir
 }
 
 func (d *TreeDir) IsDir() bool { return true }
 
+// Remove removes d and everything below it from the store, and stops
+// monitoring them.
+func (d *TreeDir) Remove() bool {
+	d.clearRows()
+	return d.TreeFile.Remove()
+}
+
 func (d *TreeDir) Clear() {
 	for filePath, TreeEntry := range d.child {
 		TreeEntry.Remove()
@@ -261,6 +281,9 @@ func (d *TreeDir) refreshOnce(ctx context.Context, done func()) {
 
 	gtkutil.Async(ctx, func() func() {
 		files, err := os.ReadDir(d.filePath)
+		if err == nil {
+			files = d.filterFiles(files)
+		}
 
 		return func() {
 			defer finalize()
@@ -304,6 +327,10 @@ func (d *TreeDir) refreshOnce(ctx context.Context, done func()) {
 				var it *gtk.TreeIter
 				// See if we can grab the entry's iterator directly.
 				if entry != nil {
+					if dir, ok := entry.(*TreeDir); ok {
+						// Became a file, so drop the directory's children.
+						dir.clearRows()
+					}
 					if iter, ok := entry.TreeIter(); ok {
 						it = iter
 					}
@@ -316,7 +343,7 @@ func (d *TreeDir) refreshOnce(ctx context.Context, done func()) {
 				path := filepath.Join(d.filePath, file.Name())
 
 				if file.IsDir() {
-					entry = newTreeDir(d.store, it, path)
+					entry = newTreeDir(d.store, d.filter, it, path)
 				} else {
 					entry = newTreeFile(d.store, it, path)
 				}
@@ -325,9 +352,133 @@ func (d *TreeDir) refreshOnce(ctx context.Context, done func()) {
 				child[file.Name()] = entry
 			}
 
+			// Remove the files that are gone.
+			for name, entry := range d.child {
+				if _, ok := child[name]; !ok {
+					entry.Remove()
+				}
+			}
+
 			d.child = child
+			// Rows of new files were appended after the existing ones.
+			d.sortRows()
+		}
+	})
+}
+
+// sortRows moves the rows of the children into the order of the listing, which
+// is directories first and then by name. Nothing is moved if they're already
+// in order.
+func (d *TreeDir) sortRows() {
+	names := make([]string, 0, len(d.child))
+	for name := range d.child {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		idir := d.child[names[i]].IsDir()
+		jdir := d.child[names[j]].IsDir()
+		if idir != jdir {
+			return idir
 		}
+		return names[i] < names[j]
 	})
+
+	last := -1
+	sorted := true
+	for _, name := range names {
+		path := d.child[name].TreePath()
+		if path == nil {
+			continue
+		}
+		indices := path.Indices()
+		if i := indices[len(indices)-1]; i > last {
+			last = i
+			continue
+		}
+		sorted = false
+		break
+	}
+	if sorted {
+		return
+	}
+
+	// Moving each row to the end in order leaves them sorted.
+	for _, name := range names {
+		if iter, ok := d.child[name].TreeIter(); ok {
+			d.store.MoveBefore(iter, nil)
+		}
+	}
+}
+
+// filterFiles returns the files that aren't hidden from the tree. It may read
+// ignore files.
+func (d *TreeDir) filterFiles(files []fs.DirEntry) []fs.DirEntry {
+	hidden := d.filter.hider(d.filePath)
+
+	filtered := files[:0]
+	for _, file := range files {
+		if !hidden(file.Name(), file.IsDir()) {
+			filtered = append(filtered, file)
+		}
+	}
+
+	return filtered
+}
+
+// add adds the file at the given path into the directory using the given row,
+// or a new row if it's nil. Nothing is done if the directory isn't initialized,
+// since the file will be listed once it is.
+func (d *TreeDir) add(iter *gtk.TreeIter, path string, info fs.FileInfo) TreeEntry {
+	if d.child == nil {
+		if iter != nil {
+			d.store.Remove(iter)
+		}
+		return nil
+	}
+
+	if old, ok := d.child[filepath.Base(path)]; ok {
+		// Already listed, likely by a refresh after the change on disk.
+		if iter != nil {
+			old.Remove()
+		} else {
+			if dir, ok := old.(*TreeDir); ok {
+				dir.clearRows()
+			}
+			iter, _ = old.TreeIter()
+		}
+	}
+
+	if iter == nil {
+		root, ok := d.TreeIter()
+		if !ok {
+			return nil
+		}
+		iter = d.store.Append(root)
+	}
+
+	var entry TreeEntry
+	if info.IsDir() {
+		entry = newTreeDir(d.store, d.filter, iter, path)
+	} else {
+		entry = newTreeFile(d.store, iter, path)
+	}
+
+	d.store.Set(iter, allTreeColumns, fileColumnValues(path, fs.FileInfoToDirEntry(info)))
+	d.child[filepath.Base(path)] = entry
+	d.sortRows()
+	return entry
+}
+
+// clearRows removes all rows below the directory, including the placeholder,
+// and stops monitoring the directory.
+func (d *TreeDir) clearRows() {
+	d.unwatch()
+	d.Clear()
+	if d.temp != nil {
+		d.store.Remove(d.temp)
+		d.temp = nil
+	}
 }
 
 type treeRoot struct {
@@ -345,10 +496,11 @@ func newTreeRoot(path string) treeRoot {
 		*glib.NewValue(path),
 		*glib.NewValue(""),
 		*glib.NewValue(true),
+		*glib.NewValue(""),
 	})
 
 	return treeRoot{
-		TreeDir: *newTreeDir(store, root, path),
+		TreeDir: *newTreeDir(store, newFileFilter(path), root, path),
 	}
 }
 
@@ -359,12 +511,12 @@ func (r *treeRoot) Model() gtk.TreeModeller {
 
 // RootPath returns the root TreePath.
 func (r *treeRoot) RootPath() *gtk.TreePath {
-	return r.treePath
+	return r.TreePath()
 }
 
 // RootIter returns the root node's TreeIter.
 func (r *treeRoot) RootIter() *gtk.TreeIter {
-	iter, ok := r.store.Iter(r.treePath)
+	iter, ok := r.TreeIter()
 	if !ok {
 		panic("BUG: RootIter cannot find root node")
 	}
@@ -0,0 +1,25 @@
+package fileutil
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gio/v2"
+
+	coreglib "github.com/diamondburned/gotk4/pkg/core/glib"
+)
+
+// FileValue returns a GFile value of the given path for use in drag-and-drop.
+func FileValue(path string) *coreglib.Value {
+	v := coreglib.InitValue(gio.GTypeFile)
+	v.SetObject(coreglib.InternObject(gio.NewFileForPath(path)))
+	return v
+}
+
+// DroppedPath returns the path of the GFile dropped by a DropTarget of type
+// gio.GTypeFile. False is returned if the file isn't a local one.
+func DroppedPath(value *coreglib.Value) (string, bool) {
+	file, ok := value.GoValue().(gio.Filer)
+	if !ok {
+		return "", false
+	}
+	path := file.Path()
+	return path, path != ""
+}
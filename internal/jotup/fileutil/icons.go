@@ -1,6 +1,11 @@
-package filetree
+// Package fileutil contains helpers for local files that are shared by the file
+// tree and the editor.
+package fileutil
 
-import "strings"
+import (
+	"path/filepath"
+	"strings"
+)
 
 var iconExts = map[string]map[string]struct{}{
 	"audio-x-generic-symbolic": strset(
@@ -34,6 +39,12 @@ var iconExts = map[string]map[string]struct{}{
 	),
 }
 
+// IconName returns the name of the symbolic icon for the file at the given path,
+// judging by its extension.
+func IconName(path string) string {
+	return iconExt(filepath.Ext(path))
+}
+
 func iconExt(ext string) string {
 	ext = strings.ToLower(ext)
 	ext = strings.TrimPrefix(ext, ".")
@@ -55,3 +66,9 @@ func strset(strs ...string) map[string]struct{} {
 	}
 	return m
 }
+
+// IsImage returns true if the file at the given path is an image, judging by
+// its extension.
+func IsImage(path string) bool {
+	return iconExt(filepath.Ext(path)) == "image-x-generic-symbolic"
+}
@@ -0,0 +1,195 @@
+// Package fuzzy ranks strings by how well they match a pattern whose
+// characters appear in them in order, such as "mtng" in "notes/meeting.md".
+package fuzzy
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scores of a matched character.
+const (
+	scoreMatch       = 16
+	scoreBoundary    = 24 // at the start of a word
+	scoreConsecutive = 16 // right after the previous match
+	scoreBasename    = 8  // after the last slash
+	scoreCase        = 4  // the exact same case
+	penaltyGap       = 1  // per skipped character, up to maxGapPenalty
+	maxGapPenalty    = 8
+)
+
+// Match is a matched string.
+type Match struct {
+	// Index is the index of the string in the list given to Find.
+	Index int
+	Score int
+	// Positions are the byte offsets of the matched characters.
+	Positions []int
+}
+
+// Find returns the strings matching the pattern, best first. Ties are broken
+// by the shorter string, then by the order in the list. All strings match an
+// empty pattern.
+func Find(pattern string, strs []string) []Match {
+	var matches []Match
+	for i, s := range strs {
+		if score, positions, ok := Score(pattern, s); ok {
+			matches = append(matches, Match{Index: i, Score: score, Positions: positions})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return len(strs[matches[i].Index]) < len(strs[matches[j].Index])
+	})
+
+	return matches
+}
+
+// Score returns the score of s for the pattern and the byte offsets of the
+// matched characters. Matching ignores case and spaces in the pattern. False is
+// returned if the characters of the pattern don't all appear in s in order.
+func Score(pattern, s string) (int, []int, bool) {
+	p := []rune(strings.ReplaceAll(pattern, " ", ""))
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+
+	runes, offsets := decode(s)
+	basename := strings.LastIndexByte(s, '/') + 1
+
+	best := -1
+	var bestPositions []int
+
+	// Try every occurrence of the first character as the start, since the
+	// first one isn't necessarily the best.
+	for start := range runes {
+		if !equalFold(runes[start], p[0]) {
+			continue
+		}
+
+		score, positions, ok := scoreFrom(p, runes, offsets, start, basename)
+		if !ok {
+			// Later starts can't match either.
+			break
+		}
+		if score > best {
+			best = score
+			bestPositions = positions
+		}
+	}
+
+	if best < 0 {
+		return 0, nil, false
+	}
+
+	// Prefer shorter strings.
+	best -= len(runes) / 8
+
+	return best, bestPositions, true
+}
+
+// scoreFrom greedily matches the pattern starting at the given rune.
+func scoreFrom(p, runes []rune, offsets []int, start, basename int) (int, []int, bool) {
+	positions := make([]int, 0, len(p))
+	score := 0
+	last := -1
+
+	j := 0
+	for i := start; i < len(runes) && j < len(p); i++ {
+		if !equalFold(runes[i], p[j]) {
+			continue
+		}
+
+		score += scoreMatch
+		if runes[i] == p[j] {
+			score += scoreCase
+		}
+		if isBoundary(runes, i) {
+			score += scoreBoundary
+		}
+		if offsets[i] >= basename {
+			score += scoreBasename
+		}
+		if last >= 0 {
+			if last == i-1 {
+				score += scoreConsecutive
+			} else if gap := i - last - 1; gap < maxGapPenalty {
+				score -= gap * penaltyGap
+			} else {
+				score -= maxGapPenalty * penaltyGap
+			}
+		}
+
+		positions = append(positions, offsets[i])
+		last = i
+		j++
+	}
+
+	return score, positions, j == len(p)
+}
+
+// isBoundary returns true if the rune at i starts a word.
+func isBoundary(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev, r := runes[i-1], runes[i]
+	switch prev {
+	case '/', '\\', '-', '_', '.', ' ':
+		return true
+	}
+
+	return unicode.IsLower(prev) && unicode.IsUpper(r)
+}
+
+func equalFold(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// decode returns the runes of s and their byte offsets.
+func decode(s string) ([]rune, []int) {
+	runes := make([]rune, 0, len(s))
+	offsets := make([]int, 0, len(s))
+
+	for i, r := range s {
+		runes = append(runes, r)
+		offsets = append(offsets, i)
+	}
+
+	return runes, offsets
+}
+
+// Markup returns s as Pango markup with the matched characters in bold.
+func Markup(s string, positions []int) string {
+	var b strings.Builder
+	b.Grow(len(s) + len(positions)*7)
+
+	last := 0
+	for i := 0; i < len(positions); {
+		start := positions[i]
+		end := start
+
+		// Embolden consecutive characters together.
+		for ; i < len(positions) && positions[i] == end; i++ {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+		}
+
+		b.WriteString(html.EscapeString(s[last:start]))
+		b.WriteString("<b>")
+		b.WriteString(html.EscapeString(s[start:end]))
+		b.WriteString("</b>")
+
+		last = end
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+
+	return b.String()
+}
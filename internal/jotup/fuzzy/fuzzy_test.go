@@ -0,0 +1,112 @@
+package fuzzy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		s         string
+		ok        bool
+		positions []int
+	}{
+		{"", "anything", true, nil},
+		{"mtng", "notes/meeting.md", true, []int{6, 9, 11, 12}},
+		{"MTNG", "notes/meeting.md", true, []int{6, 9, 11, 12}},
+		{"n m", "notes/meeting.md", true, []int{0, 6}},
+		{"gnm", "notes/meeting.md", false, nil},
+		{"x", "notes/meeting.md", false, nil},
+		{"ünï", "über/ünïcode", true, []int{6, 8, 9}},
+		// The later start matches consecutively.
+		{"ab", "a-x-ab", true, []int{4, 5}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern+"/"+test.s, func(t *testing.T) {
+			_, positions, ok := Score(test.pattern, test.s)
+			if ok != test.ok {
+				t.Fatalf("expected ok %v, got %v", test.ok, ok)
+			}
+			if !reflect.DeepEqual(positions, test.positions) {
+				t.Errorf("expected positions %v, got %v", test.positions, positions)
+			}
+		})
+	}
+}
+
+func TestScoreRanking(t *testing.T) {
+	// Each pattern must score better on the first string than on the second.
+	tests := []struct {
+		pattern string
+		better  string
+		worse   string
+		reason  string
+	}{
+		{"meet", "notes/meeting.md", "notes/mxexext.md", "consecutive"},
+		{"nm", "notes/meeting.md", "xnxmx.md", "word boundaries"},
+		{"todo", "notes/todo.md", "todo/notes.md", "basename"},
+		{"Todo", "Todo.md", "todo.md", "case"},
+		{"abc", "abc.md", "abc/long/path/to.md", "shorter"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.reason, func(t *testing.T) {
+			better, _, ok1 := Score(test.pattern, test.better)
+			worse, _, ok2 := Score(test.pattern, test.worse)
+			if !ok1 || !ok2 {
+				t.Fatalf("expected both to match, got %v and %v", ok1, ok2)
+			}
+			if better <= worse {
+				t.Errorf("%q scored %d, not better than %q with %d", test.better, better, test.worse, worse)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	strs := []string{
+		"archive/old-meeting-notes.md",
+		"readme.md",
+		"notes/meeting.md",
+		"meeting.md",
+	}
+
+	var indices []int
+	for _, match := range Find("meeting", strs) {
+		indices = append(indices, match.Index)
+	}
+
+	if expected := []int{3, 2, 0}; !reflect.DeepEqual(indices, expected) {
+		t.Errorf("expected matches %v, got %v", expected, indices)
+	}
+
+	if matches := Find("", strs); len(matches) != len(strs) {
+		t.Errorf("expected all strings to match an empty pattern, got %d", len(matches))
+	}
+}
+
+func TestMarkup(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		positions []int
+		markup    string
+	}{
+		{"none", "a<b>.md", nil, "a&lt;b&gt;.md"},
+		{"consecutive", "meeting.md", []int{0, 1, 2}, "<b>mee</b>ting.md"},
+		{"separate", "notes/meeting.md", []int{0, 6}, "<b>n</b>otes/<b>m</b>eeting.md"},
+		{"escaped", "a&b", []int{1}, "a<b>&amp;</b>b"},
+		{"multibyte", "über/ünï", []int{0, 6, 8}, "<b>ü</b>ber/<b>ün</b>ï"},
+		{"end", "ab", []int{1}, "a<b>b</b>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if markup := Markup(test.s, test.positions); markup != test.markup {
+				t.Errorf("expected %q, got %q", test.markup, markup)
+			}
+		})
+	}
+}
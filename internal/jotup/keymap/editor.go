@@ -0,0 +1,195 @@
+package keymap
+
+import (
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+)
+
+var editorCSS = cssutil.Applier("keymap-editor", `
+	.keymap-editor {
+		margin-top: 8px;
+	}
+	.keymap-row {
+		padding: 2px 0;
+	}
+	.keymap-row entry {
+		min-width: 200px;
+	}
+`)
+
+// newEditor creates the widget that lists all actions for rebinding them.
+func newEditor(save func()) gtk.Widgetter {
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	editorCSS(box)
+
+	var rows []*bindingRow
+	for _, binding := range Bindings() {
+		row := newBindingRow(binding, save)
+		rows = append(rows, row)
+		box.Append(row)
+	}
+
+	keys.SubscribeWidget(box, func() {
+		for _, row := range rows {
+			row.update()
+		}
+	})
+
+	return box
+}
+
+// bindingRow shows the shortcuts of an action. They can be typed in or
+// recorded by pressing them.
+type bindingRow struct {
+	*gtk.Box
+	entry    *gtk.Entry
+	conflict *gtk.Image
+	reset    *gtk.Button
+
+	binding   Binding
+	save      func()
+	recording bool
+}
+
+func newBindingRow(binding Binding, save func()) *bindingRow {
+	r := bindingRow{
+		binding: binding,
+		save:    save,
+	}
+
+	label := gtk.NewLabel(binding.Label)
+	label.SetXAlign(0)
+	label.SetHExpand(true)
+	label.SetTooltipText(binding.Action)
+
+	r.conflict = gtk.NewImageFromIconName("dialog-warning-symbolic")
+	r.conflict.AddCSSClass("warning")
+
+	r.entry = gtk.NewEntry()
+	r.entry.SetObjectProperty("placeholder-text", "Disabled")
+	r.entry.ConnectChanged(r.apply)
+
+	keyer := gtk.NewEventControllerKey()
+	keyer.SetPropagationPhase(gtk.PhaseCapture)
+	keyer.ConnectKeyPressed(r.record)
+	r.entry.AddController(keyer)
+
+	record := gtk.NewButtonFromIconName("media-record-symbolic")
+	record.SetTooltipText("Record Shortcut")
+	record.ConnectClicked(func() {
+		r.recording = true
+		r.entry.SetText("")
+		r.entry.SetObjectProperty("placeholder-text", "Press a shortcut...")
+		r.entry.GrabFocus()
+	})
+
+	r.reset = gtk.NewButtonFromIconName("edit-undo-symbolic")
+	r.reset.SetTooltipText("Reset to Default")
+	r.reset.ConnectClicked(func() {
+		Reset(r.binding.Action)
+		r.save()
+	})
+
+	r.Box = gtk.NewBox(gtk.OrientationHorizontal, 6)
+	r.Box.AddCSSClass("keymap-row")
+	r.Box.Append(label)
+	r.Box.Append(r.conflict)
+	r.Box.Append(r.entry)
+	r.Box.Append(record)
+	r.Box.Append(r.reset)
+
+	r.update()
+	return &r
+}
+
+// update shows the current shortcuts and conflicts.
+func (r *bindingRow) update() {
+	accels := Accels(r.binding.Action)
+
+	// Don't overwrite what the user is typing if it means the same.
+	typed, ok := ParseAccels(r.entry.Text())
+	if !ok || !equalAccels(typed, accels) {
+		r.entry.SetText(FormatAccels(accels))
+	}
+
+	if conflicts := Conflicts(r.binding.Action); len(conflicts) > 0 {
+		labels := make([]string, len(conflicts))
+		for i, action := range conflicts {
+			labels[i] = Label(action)
+		}
+		r.conflict.SetTooltipText("Also used by " + strings.Join(labels, ", "))
+		r.conflict.Show()
+	} else {
+		r.conflict.Hide()
+	}
+
+	r.reset.SetSensitive(!IsDefault(r.binding.Action))
+}
+
+// apply rebinds the action to the typed shortcuts if they're valid.
+func (r *bindingRow) apply() {
+	if r.recording {
+		return
+	}
+
+	accels, ok := ParseAccels(r.entry.Text())
+	if !ok {
+		r.entry.AddCSSClass("error")
+		return
+	}
+	r.entry.RemoveCSSClass("error")
+
+	if equalAccels(accels, Accels(r.binding.Action)) {
+		return
+	}
+
+	SetAccels(r.binding.Action, accels)
+	r.save()
+}
+
+// record handles key presses while recording. Escape cancels and Backspace
+// disables the action.
+func (r *bindingRow) record(val, _ uint, mods gdk.ModifierType) bool {
+	if !r.recording {
+		return false
+	}
+
+	k := eventKey(val, mods)
+
+	switch {
+	case k.mods == 0 && k.val == gdk.KEY_Escape:
+		r.stopRecording()
+		r.update()
+	case k.mods == 0 && k.val == gdk.KEY_BackSpace:
+		r.stopRecording()
+		r.apply() // the text was already cleared
+	case gtk.AcceleratorValid(k.val, k.mods):
+		r.stopRecording()
+		r.entry.SetText(gtk.AcceleratorName(k.val, k.mods))
+	}
+
+	// Modifiers on their own are waited out.
+	return true
+}
+
+func (r *bindingRow) stopRecording() {
+	r.recording = false
+	r.entry.SetObjectProperty("placeholder-text", "Disabled")
+}
+
+func equalAccels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ka, ok1 := parse(a[i])
+		kb, ok2 := parse(b[i])
+		if !ok1 || !ok2 || ka != kb {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,391 @@
+// Package keymap maps actions to keyboard shortcuts. Packages register their
+// actions with default shortcuts, which the user can rebind in the preferences.
+package keymap
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app/prefs"
+)
+
+// Binding is an action that can be bound to keyboard shortcuts.
+type Binding struct {
+	// Action is the name of the action, such as "editor.save".
+	Action string
+	// Label is the name of the action shown to the user.
+	Label string
+	// Accels are the default shortcuts as accepted by gtk.AcceleratorParse.
+	Accels []string
+	// Builtin is true if the default shortcuts are also built into the widget,
+	// such as Ctrl+C in text views. They are blocked once they're rebound, so
+	// that the built-in doesn't keep firing.
+	Builtin bool
+}
+
+type key struct {
+	val  uint
+	mods gdk.ModifierType
+}
+
+// parse parses the accelerator into a key. False is returned if it's invalid.
+func parse(accel string) (key, bool) {
+	val, mods, ok := gtk.AcceleratorParse(accel)
+	if !ok || val == 0 {
+		return key{}, false
+	}
+	return key{val, mods}, true
+}
+
+// eventKey normalizes a key event like AcceleratorParse does, so that lock
+// keys don't get in the way.
+func eventKey(val uint, mods gdk.ModifierType) key {
+	return key{gdk.KeyvalToLower(val), mods & gtk.AcceleratorGetDefaultModMask()}
+}
+
+// eventKeys returns the keys that a key event may be bound as. Shift is
+// usually needed to type symbols, which shortcuts such as <Ctrl>percent leave
+// out, so the key without Shift is tried after the one with it.
+func eventKeys(val uint, mods gdk.ModifierType) []key {
+	k := eventKey(val, mods)
+	if k.mods&gdk.ShiftMask == 0 || !isSymbol(k.val) {
+		return []key{k}
+	}
+	return []key{k, {k.val, k.mods &^ gdk.ShiftMask}}
+}
+
+// isSymbol returns true if the key types a visible character without case.
+func isSymbol(val uint) bool {
+	r := rune(gdk.KeyvalToUnicode(val))
+	return unicode.IsPrint(r) && !unicode.IsSpace(r) && gdk.KeyvalToUpper(val) == val
+}
+
+type keymapProp struct {
+	prefs.Pubsub
+	prefs.PropMeta
+	mut      sync.Mutex
+	bindings map[string]Binding
+	user     map[string][]string // overrides of the defaults
+	index    map[key][]string    // nil if outdated
+	blocked  map[key][]string    // rebound built-in shortcuts, with index
+}
+
+var keys = &keymapProp{
+	Pubsub: *prefs.NewPubsub(),
+	PropMeta: prefs.PropMeta{
+		Name:        "Keyboard Shortcuts",
+		Section:     "Keybindings",
+		Description: "Shortcuts are separated by commas, such as &lt;Ctrl&gt;S, F2.",
+	},
+	bindings: make(map[string]Binding),
+	user:     make(map[string][]string),
+}
+
+func init() {
+	prefs.RegisterProp(keys)
+}
+
+// Register registers the actions that can be bound. It should only be called
+// during init.
+func Register(bindings ...Binding) {
+	keys.mut.Lock()
+	defer keys.mut.Unlock()
+
+	for _, binding := range bindings {
+		keys.bindings[binding.Action] = binding
+	}
+	keys.index = nil
+}
+
+// Bindings returns all registered actions sorted by their labels.
+func Bindings() []Binding {
+	keys.mut.Lock()
+	bindings := make([]Binding, 0, len(keys.bindings))
+	for _, binding := range keys.bindings {
+		bindings = append(bindings, binding)
+	}
+	keys.mut.Unlock()
+
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Label != bindings[j].Label {
+			return bindings[i].Label < bindings[j].Label
+		}
+		return bindings[i].Action < bindings[j].Action
+	})
+	return bindings
+}
+
+// Label returns the label of the action, or an empty string if it's not
+// registered.
+func Label(action string) string {
+	keys.mut.Lock()
+	defer keys.mut.Unlock()
+
+	return keys.bindings[action].Label
+}
+
+// Accels returns the current shortcuts of the action.
+func Accels(action string) []string {
+	keys.mut.Lock()
+	defer keys.mut.Unlock()
+
+	return keys.accels(action)
+}
+
+func (p *keymapProp) accels(action string) []string {
+	if accels, ok := p.user[action]; ok {
+		return accels
+	}
+	return p.bindings[action].Accels
+}
+
+// AccelLabel returns the first shortcut of the action as shown to the user, or
+// an empty string if it has none.
+func AccelLabel(action string) string {
+	for _, accel := range Accels(action) {
+		if k, ok := parse(accel); ok {
+			return gtk.AcceleratorGetLabel(k.val, k.mods)
+		}
+	}
+	return ""
+}
+
+// IsDefault returns true if the action has its default shortcuts.
+func IsDefault(action string) bool {
+	keys.mut.Lock()
+	defer keys.mut.Unlock()
+
+	_, ok := keys.user[action]
+	return !ok
+}
+
+// SetAccels rebinds the action to the given shortcuts. An empty list unbinds
+// it.
+func SetAccels(action string, accels []string) {
+	keys.mut.Lock()
+	keys.user[action] = accels
+	keys.index = nil
+	keys.mut.Unlock()
+
+	keys.Publish()
+}
+
+// Reset restores the default shortcuts of the action.
+func Reset(action string) {
+	keys.mut.Lock()
+	delete(keys.user, action)
+	keys.index = nil
+	keys.mut.Unlock()
+
+	keys.Publish()
+}
+
+// Conflicts returns the other actions that share a shortcut with the given
+// one, sorted.
+func Conflicts(action string) []string {
+	keys.mut.Lock()
+	defer keys.mut.Unlock()
+
+	index := keys.lookupIndex()
+
+	var conflicts []string
+	for _, accel := range keys.accels(action) {
+		k, ok := parse(accel)
+		if !ok {
+			continue
+		}
+		for _, other := range index[k] {
+			if other != action && !contains(conflicts, other) {
+				conflicts = append(conflicts, other)
+			}
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func contains(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the actions bound to the key press, sorted, and the actions
+// whose built-in shortcut it is although they were rebound.
+func (p *keymapProp) lookup(val uint, mods gdk.ModifierType) (actions, blocked []string) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	index := p.lookupIndex()
+	for _, k := range eventKeys(val, mods) {
+		if len(index[k]) > 0 || len(p.blocked[k]) > 0 {
+			return index[k], p.blocked[k]
+		}
+	}
+	return nil, nil
+}
+
+// lookupIndex returns the index of keys to actions, rebuilding it and the
+// blocked shortcuts if needed. The mutex must be held.
+func (p *keymapProp) lookupIndex() map[key][]string {
+	if p.index != nil {
+		return p.index
+	}
+
+	p.index = make(map[key][]string)
+	p.blocked = make(map[key][]string)
+
+	for action, binding := range p.bindings {
+		accels := p.accels(action)
+		for _, accel := range accels {
+			if k, ok := parse(accel); ok {
+				p.index[k] = append(p.index[k], action)
+			}
+		}
+
+		if !binding.Builtin {
+			continue
+		}
+		for _, accel := range binding.Accels {
+			if k, ok := parse(accel); ok && !hasKey(accels, k) {
+				p.blocked[k] = append(p.blocked[k], action)
+			}
+		}
+	}
+
+	for _, actions := range p.index {
+		sort.Strings(actions)
+	}
+	for _, actions := range p.blocked {
+		sort.Strings(actions)
+	}
+
+	return p.index
+}
+
+// hasKey returns true if any of the shortcuts is the key.
+func hasKey(accels []string, k key) bool {
+	for _, accel := range accels {
+		if other, ok := parse(accel); ok && other == k {
+			return true
+		}
+	}
+	return false
+}
+
+// BindKeys binds the shortcuts of the actions in the map to their functions.
+// The shortcuts are looked up on every key press, so rebinding takes effect
+// immediately. Functions return true to stop the key from propagating. The
+// built-in shortcuts of the actions in the map are stopped once rebound.
+func BindKeys(w gtk.Widgetter, fns map[string]func() bool) {
+	controller := gtk.NewEventControllerKey()
+	controller.SetPropagationPhase(gtk.PhaseCapture)
+	controller.ConnectKeyPressed(func(val, _ uint, mods gdk.ModifierType) bool {
+		actions, blocked := keys.lookup(val, mods)
+
+		for _, action := range actions {
+			if f, ok := fns[action]; ok && f() {
+				return true
+			}
+		}
+		for _, action := range blocked {
+			if _, ok := fns[action]; ok {
+				return true
+			}
+		}
+		return false
+	})
+
+	gtk.BaseWidget(w).AddController(controller)
+}
+
+// BindActions is like BindKeys, except the functions always handle the key.
+func BindActions(w gtk.Widgetter, actions map[string]func()) {
+	fns := make(map[string]func() bool, len(actions))
+	for action, f := range actions {
+		f := f
+		fns[action] = func() bool {
+			f()
+			return true
+		}
+	}
+
+	BindKeys(w, fns)
+}
+
+// ParseAccels splits a comma-separated list of shortcuts. False is returned if
+// any of them is invalid.
+func ParseAccels(text string) ([]string, bool) {
+	accels := []string{}
+	for _, accel := range strings.Split(text, ",") {
+		accel = strings.TrimSpace(accel)
+		if accel == "" {
+			continue
+		}
+
+		k, ok := parse(accel)
+		if !ok {
+			return nil, false
+		}
+		accels = append(accels, gtk.AcceleratorName(k.val, k.mods))
+	}
+	return accels, true
+}
+
+// FormatAccels joins the shortcuts into a list that ParseAccels accepts.
+func FormatAccels(accels []string) string {
+	return strings.Join(accels, ", ")
+}
+
+func (p *keymapProp) MarshalJSON() ([]byte, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	return json.Marshal(p.user)
+}
+
+func (p *keymapProp) UnmarshalJSON(blob []byte) error {
+	var user map[string][]string
+	if err := json.Unmarshal(blob, &user); err != nil {
+		return err
+	}
+
+	for action, accels := range user {
+		for _, accel := range accels {
+			if _, ok := parse(accel); !ok {
+				log.Printf("keymap: ignoring invalid shortcut %q of %s", accel, action)
+			}
+		}
+	}
+
+	if user == nil {
+		user = make(map[string][]string)
+	}
+
+	p.mut.Lock()
+	p.user = user
+	p.index = nil
+	p.mut.Unlock()
+
+	p.Publish()
+	return nil
+}
+
+// CreateWidget creates a list of all actions with their shortcuts.
+func (p *keymapProp) CreateWidget(ctx context.Context, save func()) gtk.Widgetter {
+	return newEditor(save)
+}
+
+// WidgetIsLarge returns true.
+func (p *keymapProp) WidgetIsLarge() bool { return true }
@@ -0,0 +1,91 @@
+package jotup
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/app"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+)
+
+// actionLabel returns the name of the action shown to the user.
+func actionLabel(action string) string {
+	if label := keymap.Label(action); label != "" {
+		return label
+	}
+
+	// "editor.some-action" becomes "Some action".
+	name := action[strings.IndexByte(action, '.')+1:]
+	name = strings.ReplaceAll(name, "-", " ")
+	if name == "" {
+		return action
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// Actions returns all actions that can be run in the window, each with the
+// function that activates it on the widget owning it.
+func (w *Window) Actions() map[string]func() bool {
+	actions := make(map[string]func() bool)
+
+	activateOn := func(widget gtk.Widgetter, action string) {
+		actions[action] = func() bool {
+			return gtk.BaseWidget(widget).ActivateAction(action, nil)
+		}
+	}
+
+	// The editor sees all actions up to the app.
+	for action := range w.actions {
+		activateOn(w.Editor, action)
+	}
+	for action := range w.Editor.actions {
+		activateOn(w.Editor, action)
+	}
+	for _, action := range app.FromContext(w.ctx).ListActions() {
+		activateOn(w.Editor, "app."+action)
+	}
+	for _, action := range w.Editor.Files.ActionNames() {
+		activateOn(w.Editor.Files.Scroll.View, action)
+	}
+	for _, action := range w.Editor.Tabs.ActionNames() {
+		action := action
+		// The tabs of the current pane, which may change.
+		actions[action] = func() bool {
+			return w.Editor.Tabs.ActivateAction(action, nil)
+		}
+	}
+
+	return actions
+}
+
+// CommandPalette shows a picker of all actions and runs the chosen one.
+func (w *Window) CommandPalette() {
+	activate := w.Actions()
+	delete(activate, "win.command-palette")
+
+	actions := make([]string, 0, len(activate))
+	for action := range activate {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	items := make([]pickerItem, len(actions))
+	for i, action := range actions {
+		items[i] = pickerItem{
+			Text:   actionLabel(action),
+			Detail: keymap.AccelLabel(action),
+		}
+	}
+
+	picker := newPicker(w.Editor.Right, "Run a command", func(i int) {
+		if !activate[actions[i]]() {
+			log.Println("cannot activate action", actions[i])
+		}
+	})
+	picker.SetItems(items)
+
+	gtkutil.PopupFinally(picker)
+}
@@ -0,0 +1,186 @@
+package jotup
+
+import (
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/jotup/internal/jotup/editor"
+)
+
+// paneSplit splits the editor area between two panes. Each pane is either an
+// EditorTabs or another paneSplit.
+type paneSplit struct {
+	*gtk.Paned
+	children [2]gtk.Widgetter
+}
+
+func newPaneSplit(orientation gtk.Orientation) *paneSplit {
+	paned := gtk.NewPaned(orientation)
+	paned.SetResizeStartChild(true)
+	paned.SetResizeEndChild(true)
+	paned.SetShrinkStartChild(false)
+	paned.SetShrinkEndChild(false)
+
+	return &paneSplit{Paned: paned}
+}
+
+func (s *paneSplit) set(i int, w gtk.Widgetter) {
+	s.children[i] = w
+	if i == 0 {
+		s.SetStartChild(w)
+	} else {
+		s.SetEndChild(w)
+	}
+}
+
+func (s *paneSplit) index(w gtk.Widgetter) int {
+	if s.children[0] == w {
+		return 0
+	}
+	return 1
+}
+
+// newPane creates a new empty pane. The caller must put it into the pane tree.
+func (p *EditorPage) newPane() *EditorTabs {
+	pane := NewEditorTabs(p.ctx, p)
+
+	focus := gtk.NewEventControllerFocus()
+	focus.ConnectEnter(func() { p.setActivePane(pane) })
+	pane.AddController(focus)
+
+	p.panes = append(p.panes, pane)
+	return pane
+}
+
+// setActivePane sets the pane that menu actions and newly opened files go to.
+func (p *EditorPage) setActivePane(pane *EditorTabs) {
+	if p.Tabs == pane {
+		return
+	}
+
+	if p.Tabs != nil {
+		p.Tabs.RemoveCSSClass("editor-tabs-active")
+	}
+
+	p.Tabs = pane
+	p.Tabs.AddCSSClass("editor-tabs-active")
+	p.updateTitle()
+}
+
+// Split splits the active pane in two. The new pane is put at the right or
+// the bottom depending on orientation, and it shows the same file as the
+// active pane, sharing its buffer.
+func (p *EditorPage) Split(orientation gtk.Orientation) {
+	old := p.Tabs
+
+	size := old.AllocatedWidth()
+	if orientation == gtk.OrientationVertical {
+		size = old.AllocatedHeight()
+	}
+
+	split := newPaneSplit(orientation)
+	p.replacePane(old, split)
+
+	pane := p.newPane()
+	split.set(0, old)
+	split.set(1, pane)
+	split.SetPosition(size / 2)
+	p.parents[old] = split
+	p.parents[pane] = split
+
+	if path := old.CurrentPath(); path != "" {
+		pane.Open(path)
+	}
+
+	p.setActivePane(pane)
+	pane.GrabFocus()
+}
+
+// ClosePane closes the active pane and all its tabs, unless it's the only pane.
+// The user is asked first if that would lose unsaved changes.
+func (p *EditorPage) ClosePane() {
+	pane := p.Tabs
+	if len(p.panes) < 2 {
+		return
+	}
+
+	if !pane.losesChanges() {
+		pane.CloseAll()
+		return
+	}
+
+	askDiscard(p.ctx, pane.CloseAll)
+}
+
+// removePane removes the given pane from the pane tree, giving its space to
+// its sibling. The last pane is never removed.
+func (p *EditorPage) removePane(pane *EditorTabs) {
+	split := p.parents[pane]
+	if split == nil {
+		return
+	}
+
+	i := split.index(pane)
+	sibling := split.children[1-i]
+
+	// Move the sibling out of the split before putting it where the split is.
+	split.set(1-i, emptyWidget())
+	p.replacePane(split, sibling)
+
+	delete(p.parents, pane)
+	for i, other := range p.panes {
+		if other == pane {
+			p.panes = append(p.panes[:i], p.panes[i+1:]...)
+			break
+		}
+	}
+
+	if p.Tabs == pane {
+		p.setActivePane(p.panes[0])
+	}
+}
+
+// replacePane puts w in place of old in the pane tree.
+func (p *EditorPage) replacePane(old, w gtk.Widgetter) {
+	split := p.parents[old]
+	delete(p.parents, old)
+
+	if split == nil {
+		p.Panes.Remove(old)
+		p.Panes.Append(w)
+	} else {
+		split.set(split.index(old), w)
+	}
+
+	p.parents[w] = split
+}
+
+// findView returns a View in any pane that has the file at the given path
+// open, or nil if there's none.
+func (p *EditorPage) findView(path string) *editor.View {
+	for _, pane := range p.panes {
+		for _, tab := range pane.tabs {
+			if tab.Path() == path {
+				return tab.View
+			}
+		}
+	}
+	return nil
+}
+
+// IsUnsaved returns true if any tab in any pane has unsaved changes.
+func (p *EditorPage) IsUnsaved() bool {
+	for _, pane := range p.panes {
+		if pane.IsUnsaved() {
+			return true
+		}
+	}
+	return false
+}
+
+// closeAll closes all tabs in all panes without asking, leaving a single empty
+// pane.
+func (p *EditorPage) closeAll() {
+	panes := append([]*EditorTabs(nil), p.panes...)
+	for _, pane := range panes {
+		pane.CloseAll()
+	}
+}
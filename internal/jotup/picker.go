@@ -0,0 +1,238 @@
+package jotup
+
+import (
+	"context"
+	"html"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/fuzzy"
+)
+
+// maxPickerRows is the number of best matches shown by a picker.
+const maxPickerRows = 50
+
+var pickerCSS = cssutil.Applier("picker", `
+	.picker > contents {
+		padding: 0;
+	}
+	.picker searchentry {
+		margin: 6px;
+	}
+	.picker-row {
+		padding: 4px 8px;
+	}
+	.picker-status {
+		padding: 12px;
+	}
+`)
+
+// pickerItem is an item of a picker.
+type pickerItem struct {
+	// Text is what the user's input is matched against.
+	Text string
+	// Detail is shown dimmed after the text.
+	Detail string
+}
+
+// picker is a popover for quickly choosing an item by typing a part of it.
+type picker struct {
+	*gtk.Popover
+	entry  *gtk.SearchEntry
+	list   *gtk.ListBox
+	scroll *gtk.ScrolledWindow
+	status *gtk.Label
+
+	items   []pickerItem
+	texts   []string
+	matches []fuzzy.Match
+	chosen  func(index int)
+	cancel  context.CancelFunc // stops the running filter
+}
+
+// newPicker creates a picker shown below the top of parent. chosen is called
+// with the index of the chosen item.
+func newPicker(parent gtk.Widgetter, placeholder string, chosen func(index int)) *picker {
+	p := picker{chosen: chosen}
+
+	p.entry = gtk.NewSearchEntry()
+	p.entry.SetObjectProperty("placeholder-text", placeholder)
+	p.entry.ConnectSearchChanged(p.refilter)
+	p.entry.ConnectActivate(p.choose)
+	p.entry.ConnectStopSearch(func() { p.Popdown() })
+	gtkutil.BindKeys(p.entry, map[string]func() bool{
+		"Up":   func() bool { p.moveSelection(-1); return true },
+		"Down": func() bool { p.moveSelection(+1); return true },
+	})
+
+	p.list = gtk.NewListBox()
+	p.list.SetSelectionMode(gtk.SelectionBrowse)
+	p.list.SetActivateOnSingleClick(true)
+	p.list.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		p.list.SelectRow(row)
+		p.choose()
+	})
+
+	p.scroll = gtk.NewScrolledWindow()
+	p.scroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	p.scroll.SetPropagateNaturalHeight(true)
+	p.scroll.SetMaxContentHeight(350)
+	p.scroll.SetChild(p.list)
+
+	p.status = gtk.NewLabel("")
+	p.status.AddCSSClass("dim-label")
+	p.status.AddCSSClass("picker-status")
+
+	box := gtk.NewBox(gtk.OrientationVertical, 0)
+	box.SetSizeRequest(450, -1)
+	box.Append(p.entry)
+	box.Append(p.scroll)
+	box.Append(p.status)
+
+	p.Popover = gtk.NewPopover()
+	p.Popover.SetChild(box)
+	p.Popover.SetHasArrow(false)
+	p.Popover.SetPosition(gtk.PosBottom)
+	p.Popover.SetParent(parent)
+	p.Popover.ConnectShow(func() {
+		// Point to the top center, where the header ends.
+		rect := gdk.NewRectangle(gtk.BaseWidget(parent).AllocatedWidth()/2, 0, 1, 1)
+		p.Popover.SetPointingTo(&rect)
+		p.entry.GrabFocus()
+	})
+	p.Popover.ConnectClosed(p.stopFilter)
+	pickerCSS(p.Popover)
+
+	return &p
+}
+
+// SetStatus shows the text in place of the list, such as while the items are
+// being loaded.
+func (p *picker) SetStatus(text string) {
+	p.status.SetText(text)
+	p.status.Show()
+	p.scroll.Hide()
+}
+
+// SetItems sets the items to choose from.
+func (p *picker) SetItems(items []pickerItem) {
+	p.items = items
+	p.texts = make([]string, len(items))
+	for i, item := range items {
+		p.texts[i] = item.Text
+	}
+	p.refilter()
+}
+
+// refilter asynchronously shows the best items for the current input. There
+// may be many thousands of items, so they're matched outside the main thread.
+func (p *picker) refilter() {
+	if p.items == nil {
+		// Still loading.
+		return
+	}
+
+	p.stopFilter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	pattern := p.entry.Text()
+	texts := p.texts
+
+	gtkutil.Async(ctx, func() func() {
+		matches := fuzzy.Find(pattern, texts)
+		if len(matches) > maxPickerRows {
+			matches = matches[:maxPickerRows]
+		}
+
+		return func() {
+			p.stopFilter()
+			p.setMatches(matches)
+		}
+	})
+}
+
+// stopFilter stops the running filter, if any, so that its results are never
+// shown.
+func (p *picker) stopFilter() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+func (p *picker) setMatches(matches []fuzzy.Match) {
+	p.matches = matches
+
+	for row := p.list.FirstChild(); row != nil; row = p.list.FirstChild() {
+		p.list.Remove(row)
+	}
+
+	if len(p.matches) == 0 {
+		p.SetStatus("No matches.")
+		return
+	}
+
+	for _, match := range p.matches {
+		p.list.Append(newPickerRow(p.items[match.Index], match))
+	}
+
+	p.status.Hide()
+	p.scroll.Show()
+	p.list.SelectRow(p.list.RowAtIndex(0))
+}
+
+func newPickerRow(item pickerItem, match fuzzy.Match) *gtk.ListBoxRow {
+	text := gtk.NewLabel("")
+	text.SetMarkup(fuzzy.Markup(item.Text, match.Positions))
+	text.SetXAlign(0)
+	text.SetHExpand(true)
+	text.SetEllipsize(pango.EllipsizeStart)
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 12)
+	box.AddCSSClass("picker-row")
+	box.Append(text)
+
+	if item.Detail != "" {
+		detail := gtk.NewLabel("")
+		detail.SetMarkup(`<span size="small">` + html.EscapeString(item.Detail) + `</span>`)
+		detail.AddCSSClass("dim-label")
+		box.Append(detail)
+	}
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+	return row
+}
+
+func (p *picker) moveSelection(delta int) {
+	row := p.list.SelectedRow()
+	if row == nil {
+		return
+	}
+
+	if next := p.list.RowAtIndex(row.Index() + delta); next != nil {
+		p.list.SelectRow(next)
+
+		// Keep the selection in view while the entry keeps the focus.
+		if _, y, ok := next.TranslateCoordinates(p.list, 0, 0); ok {
+			p.scroll.VAdjustment().ClampPage(y, y+float64(next.AllocatedHeight()))
+		}
+	}
+}
+
+// choose chooses the selected item and closes the picker.
+func (p *picker) choose() {
+	row := p.list.SelectedRow()
+	if row == nil || row.Index() >= len(p.matches) {
+		return
+	}
+
+	index := p.matches[row.Index()].Index
+	p.Popdown()
+	p.chosen(index)
+}
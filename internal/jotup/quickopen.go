@@ -0,0 +1,31 @@
+package jotup
+
+import (
+	"github.com/diamondburned/gotkit/gtkutil"
+)
+
+// QuickOpen shows a picker of all files in the folder and opens the chosen one.
+func (p *EditorPage) QuickOpen() {
+	if p.Files.Path() == "" {
+		return
+	}
+
+	var paths []string
+
+	picker := newPicker(p.Right, "Go to file", func(i int) {
+		p.Files.SelectPath(paths[i])
+	})
+	picker.SetStatus("Indexing files...")
+
+	p.Files.IndexFiles(func(indexed []string) {
+		paths = indexed
+
+		items := make([]pickerItem, len(paths))
+		for i, path := range paths {
+			items[i] = pickerItem{Text: p.Files.RelPath(path)}
+		}
+		picker.SetItems(items)
+	})
+
+	gtkutil.PopupFinally(picker)
+}
@@ -0,0 +1,156 @@
+package jotup
+
+import (
+	"context"
+	"os"
+
+	"github.com/diamondburned/gotkit/app/prefs"
+	"github.com/diamondburned/gotkit/app/prefs/kvstate"
+	"github.com/diamondburned/jotup/internal/jotup/editor"
+)
+
+var reopenLastSession = prefs.NewBool(true, prefs.PropMeta{
+	Name:        "Restore Last Session",
+	Section:     "Workspace",
+	Description: "Reopen the last folder and its files on startup.",
+})
+
+// sessionConfig is the state file holding the sessions of all workspaces, keyed
+// by their root paths. It lives alongside the greeter's greet.json.
+const sessionConfig = "sessions.json"
+
+// lastSessionKey is the key of the root path of the last session. It never
+// collides with a workspace key, since those are absolute paths.
+const lastSessionKey = "last"
+
+// recentSessionsKey is the key of the root paths of all saved sessions, most
+// recent first. Sessions beyond maxSessions are forgotten.
+const recentSessionsKey = "recent"
+
+// maxSessions is the number of workspaces whose sessions are kept.
+const maxSessions = 50
+
+// session is the saved state of a workspace.
+type session struct {
+	Files        []sessionFile `json:"files,omitempty"`
+	Current      string        `json:"current,omitempty"`
+	Expanded     []string      `json:"expanded,omitempty"` // relative paths
+	SideRevealed bool          `json:"side_revealed"`
+}
+
+type sessionFile struct {
+	Path        string `json:"path"`
+	HidePreview bool   `json:"hide_preview,omitempty"`
+	editor.Position
+}
+
+// SaveSession saves the open folder, its open files and their positions so
+// they can be restored later.
+func (p *EditorPage) SaveSession() {
+	root := p.Files.Path()
+	if root == "" {
+		return
+	}
+
+	s := session{
+		Current:      p.Tabs.CurrentPath(),
+		Expanded:     p.Files.ExpandedPaths(),
+		SideRevealed: p.Fold.SideIsRevealed(),
+	}
+
+	// Linked tabs share the same file, so only save each file once.
+	saved := make(map[string]bool)
+	for _, pane := range p.panes {
+		for _, view := range pane.Views() {
+			if saved[view.Path()] {
+				continue
+			}
+			saved[view.Path()] = true
+
+			s.Files = append(s.Files, sessionFile{
+				Path:        view.Path(),
+				HidePreview: !view.Preview.Visible(),
+				Position:    view.Position(),
+			})
+		}
+	}
+
+	cfg := kvstate.AcquireConfig(p.ctx, sessionConfig)
+	cfg.Set(root, s)
+	cfg.Set(lastSessionKey, root)
+
+	var recent []string
+	cfg.Get(recentSessionsKey, &recent)
+
+	recent = append([]string{root}, removeString(recent, root)...)
+	if len(recent) > maxSessions {
+		for _, old := range recent[maxSessions:] {
+			cfg.Delete(old)
+		}
+		recent = recent[:maxSessions]
+	}
+
+	cfg.Set(recentSessionsKey, recent)
+}
+
+func removeString(strs []string, str string) []string {
+	kept := strs[:0]
+	for _, s := range strs {
+		if s != str {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// restoreSession restores the saved session of the workspace at the given root
+// path, if any. The folder must already be loaded.
+func (p *EditorPage) restoreSession(root string) {
+	var s session
+
+	cfg := kvstate.AcquireConfig(p.ctx, sessionConfig)
+	if !cfg.Get(root, &s) {
+		return
+	}
+
+	for _, dir := range s.Expanded {
+		p.Files.ExpandPath(dir)
+	}
+
+	// Views only know their paths once loaded, so keep track of what's open.
+	opened := make(map[string]bool, len(s.Files))
+
+	for _, file := range s.Files {
+		// Skip files that were removed since.
+		if _, err := os.Stat(file.Path); err != nil {
+			continue
+		}
+
+		view := p.Tabs.OpenAt(file.Path, file.Position)
+		view.Preview.SetVisible(!file.HidePreview)
+		opened[file.Path] = true
+	}
+
+	if opened[s.Current] {
+		p.Tabs.Open(s.Current)
+	}
+
+	p.Fold.SetRevealSide(s.SideRevealed)
+}
+
+// lastSession returns the root path of the last saved session or an empty
+// string if there's none or if it no longer exists.
+func lastSession(ctx context.Context) string {
+	var root string
+
+	cfg := kvstate.AcquireConfig(ctx, sessionConfig)
+	if !cfg.Get(lastSessionKey, &root) || root == "" {
+		return ""
+	}
+
+	if _, err := os.Stat(root); err != nil {
+		return ""
+	}
+
+	return root
+}
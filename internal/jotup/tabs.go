@@ -0,0 +1,408 @@
+package jotup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/diamondburned/gotk4/pkg/gdk/v4"
+	"github.com/diamondburned/gotk4/pkg/glib/v2"
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/editor"
+	"github.com/diamondburned/jotup/internal/jotup/filetree"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
+)
+
+func init() {
+	keymap.Register(
+		keymap.Binding{Action: "tabs.next", Label: "Next Tab", Accels: []string{"<Ctrl>Tab"}},
+		keymap.Binding{Action: "tabs.previous", Label: "Previous Tab", Accels: []string{"<Ctrl><Shift>ISO_Left_Tab"}},
+	)
+}
+
+// EditorTabs is a tab strip holding one editor.View per open file.
+type EditorTabs struct {
+	*gtk.Notebook
+	ctx  context.Context
+	page *EditorPage
+	tabs []*editorTab
+
+	actions map[string]func()
+}
+
+var tabsCSS = cssutil.Applier("editor-tabs", `
+	.editor-tabs > header {
+		border-bottom: none;
+	}
+	.editor-tabs:not(.editor-tabs-active) > header tab:checked {
+		opacity: 0.75;
+	}
+	.editor-tab button {
+		min-width:  0;
+		min-height: 0;
+		padding:    2px;
+	}
+	.editor-tab .editor-tab-unsaved {
+		margin-left: 4px;
+	}
+`)
+
+// NewEditorTabs creates a new EditorTabs for the given EditorPage.
+func NewEditorTabs(ctx context.Context, page *EditorPage) *EditorTabs {
+	t := EditorTabs{
+		ctx:  ctx,
+		page: page,
+	}
+
+	t.Notebook = gtk.NewNotebook()
+	t.Notebook.SetScrollable(true)
+	t.Notebook.SetShowBorder(false)
+	t.Notebook.SetVExpand(true)
+	t.Notebook.SetHExpand(true)
+	t.Notebook.NotifyProperty("page", func() {
+		if page.Tabs == &t {
+			page.updateTitle()
+		}
+	})
+	tabsCSS(t.Notebook)
+
+	t.actions = map[string]func(){
+		"tabs.next":     func() { t.cycle(+1) },
+		"tabs.previous": func() { t.cycle(-1) },
+	}
+	gtkutil.BindActionMap(t, t.actions)
+	keymap.BindActions(t, t.actions)
+
+	return &t
+}
+
+// ActionNames returns the names of the actions bound to the tabs.
+func (t *EditorTabs) ActionNames() []string {
+	names := make([]string, 0, len(t.actions))
+	for name := range t.actions {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Open opens the file at the given path in a new tab. If the file is already
+// open, then its tab is switched to instead. If it's open in another pane, then
+// the new tab shares its buffer.
+func (t *EditorTabs) Open(path string) {
+	t.open(path, nil)
+}
+
+// OpenAt is like Open, except the given position is restored if the file is
+// newly loaded. The View of the tab is returned.
+func (t *EditorTabs) OpenAt(path string, pos editor.Position) *editor.View {
+	return t.open(path, &pos)
+}
+
+func (t *EditorTabs) open(path string, pos *editor.Position) *editor.View {
+	for _, tab := range t.tabs {
+		if tab.Path() == path {
+			t.SetCurrentPage(t.PageNum(tab))
+			return tab.View
+		}
+	}
+
+	linked := t.page.findView(path)
+
+	tab := newEditorTab(t, path, linked)
+	t.tabs = append(t.tabs, tab)
+
+	n := t.AppendPage(tab, tab.header)
+	t.SetTabReorderable(tab, true)
+	t.SetCurrentPage(n)
+
+	switch {
+	case linked != nil:
+		// Already loaded.
+	case pos != nil:
+		tab.LoadAt(path, *pos)
+	default:
+		tab.Load(path)
+	}
+
+	return tab.View
+}
+
+// Current returns the View of the current tab or nil if there's none.
+func (t *EditorTabs) Current() *editor.View {
+	if tab := t.tabAt(t.CurrentPage()); tab != nil {
+		return tab.View
+	}
+	return nil
+}
+
+// CurrentPath returns the path of the file in the current tab or an empty
+// string if there's none.
+func (t *EditorTabs) CurrentPath() string {
+	if view := t.Current(); view != nil {
+		return view.Path()
+	}
+	return ""
+}
+
+// Views returns the Views of all tabs in the order that they're shown.
+func (t *EditorTabs) Views() []*editor.View {
+	views := make([]*editor.View, 0, len(t.tabs))
+	for i := 0; i < t.NPages(); i++ {
+		if tab := t.tabAt(i); tab != nil {
+			views = append(views, tab.View)
+		}
+	}
+	return views
+}
+
+// IsUnsaved returns true if any of the tabs has unsaved changes.
+func (t *EditorTabs) IsUnsaved() bool {
+	for _, tab := range t.tabs {
+		if tab.IsUnsaved() {
+			return true
+		}
+	}
+	return false
+}
+
+// losesChanges returns true if closing all tabs would lose unsaved changes,
+// which are still kept if another pane shares the buffer.
+func (t *EditorTabs) losesChanges() bool {
+	for _, tab := range t.tabs {
+		if tab.IsUnsaved() && !tab.IsLinked() {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscardChanges discards the unsaved changes of all tabs.
+func (t *EditorTabs) DiscardChanges() {
+	for _, tab := range t.tabs {
+		tab.DiscardChanges()
+	}
+}
+
+// Close closes the tab containing the given View. The user is asked first if
+// it has unsaved changes.
+func (t *EditorTabs) Close(view *editor.View) {
+	for _, tab := range t.tabs {
+		if tab.View == view {
+			tab.AskBufferDestroy(func() { t.remove(tab) })
+			return
+		}
+	}
+}
+
+// CloseAll closes all tabs without asking. Unsaved changes are discarded
+// unless another pane shares the buffer. The pane itself is removed if it's
+// not the only one.
+func (t *EditorTabs) CloseAll() {
+	for len(t.tabs) > 0 {
+		tab := t.tabs[len(t.tabs)-1]
+		if !tab.IsLinked() {
+			tab.DiscardChanges()
+		}
+		t.remove(tab)
+	}
+
+	t.page.removePane(t)
+}
+
+func (t *EditorTabs) remove(tab *editorTab) {
+	for i, other := range t.tabs {
+		if other == tab {
+			t.tabs = append(t.tabs[:i], t.tabs[i+1:]...)
+			break
+		}
+	}
+
+	if n := t.PageNum(tab); n >= 0 {
+		t.RemovePage(n)
+	}
+
+	tab.Close()
+	t.page.updateTitle()
+
+	if len(t.tabs) == 0 {
+		t.page.removePane(t)
+	}
+}
+
+// cycle switches to the tab delta tabs away from the current one, wrapping
+// around at both ends.
+func (t *EditorTabs) cycle(delta int) {
+	n := t.NPages()
+	if n < 2 {
+		return
+	}
+	t.SetCurrentPage(((t.CurrentPage()+delta)%n + n) % n)
+}
+
+func (t *EditorTabs) tabAt(n int) *editorTab {
+	if n < 0 {
+		return nil
+	}
+	for _, tab := range t.tabs {
+		if t.PageNum(tab) == n {
+			return tab
+		}
+	}
+	return nil
+}
+
+// relocateFiles updates the tabs of the files at or under oldPath after they
+// were moved to newPath.
+func (p *EditorPage) relocateFiles(oldPath, newPath string) {
+	type move struct {
+		tab  *editorTab
+		path string
+	}
+
+	// Collect everything first, since linked tabs are relocated together.
+	var moves []move
+	for _, pane := range p.panes {
+		for _, tab := range pane.tabs {
+			if path, ok := filetree.MovedPath(tab.Path(), oldPath, newPath); ok {
+				moves = append(moves, move{tab, path})
+			}
+		}
+	}
+
+	for _, move := range moves {
+		move.tab.Relocate(move.path)
+	}
+
+	p.updateTitle()
+}
+
+// closeRemovedFiles closes the tabs of the files at or under the given path
+// after they were removed. Tabs with unsaved changes are kept open so that they
+// can still be saved.
+func (p *EditorPage) closeRemovedFiles(path string) {
+	type closing struct {
+		pane *EditorTabs
+		tab  *editorTab
+	}
+
+	var closings []closing
+	for _, pane := range p.panes {
+		for _, tab := range pane.tabs {
+			if filetree.IsWithin(tab.Path(), path) && !tab.IsUnsaved() {
+				closings = append(closings, closing{pane, tab})
+			}
+		}
+	}
+
+	for _, c := range closings {
+		c.pane.remove(c.tab)
+	}
+}
+
+// editorTab is a single tab in EditorTabs. It is the editor.Controller of its
+// own View.
+type editorTab struct {
+	*editor.View
+	tabs *EditorTabs
+
+	header  *gtk.Box
+	name    *gtk.Label
+	unsaved *gtk.Label
+}
+
+func newEditorTab(tabs *EditorTabs, path string, linked *editor.View) *editorTab {
+	tab := editorTab{tabs: tabs}
+	if linked != nil {
+		tab.View = editor.NewLinkedView(tabs.ctx, &tab, linked)
+	} else {
+		tab.View = editor.NewView(tabs.ctx, &tab)
+	}
+
+	tab.name = gtk.NewLabel(filepath.Base(path))
+
+	tab.unsaved = gtk.NewLabel(filetree.UnsavedDot)
+	tab.unsaved.AddCSSClass("editor-tab-unsaved")
+	tab.unsaved.SetVisible(tab.IsUnsaved())
+
+	closeButton := gtk.NewButtonFromIconName("window-close-symbolic")
+	closeButton.AddCSSClass("flat")
+	closeButton.SetTooltipText("Close")
+	closeButton.ConnectClicked(func() { tabs.Close(tab.View) })
+
+	middle := gtk.NewGestureClick()
+	middle.SetButton(gdk.BUTTON_MIDDLE)
+	middle.ConnectPressed(func(int, float64, float64) { tabs.Close(tab.View) })
+
+	tab.header = gtk.NewBox(gtk.OrientationHorizontal, 2)
+	tab.header.AddCSSClass("editor-tab")
+	tab.header.SetTooltipText(tabs.page.Files.RelPath(path))
+	tab.header.Append(tab.name)
+	tab.header.Append(tab.unsaved)
+	tab.header.Append(closeButton)
+	tab.header.AddController(middle)
+
+	return &tab
+}
+
+// InvalidatePath implements editor.Controller.
+func (tab *editorTab) InvalidatePath() {
+	tab.name.SetText(filepath.Base(tab.Path()))
+	tab.header.SetTooltipText(tab.tabs.page.Files.RelPath(tab.Path()))
+
+	if tab.tabs.page.Tabs.Current() == tab.View {
+		tab.tabs.page.updateTitle()
+	}
+}
+
+// InvalidateUnsaved implements editor.Controller.
+func (tab *editorTab) InvalidateUnsaved() {
+	unsaved := tab.IsUnsaved()
+	tab.unsaved.SetVisible(unsaved)
+	tab.tabs.page.Files.SetUnsaved(tab.Path(), unsaved)
+
+	if tab.tabs.page.Tabs.Current() == tab.View {
+		tab.tabs.page.updateTitle()
+	}
+}
+
+// AskBufferDestroy implements editor.Controller. Closing a tab that shares its
+// buffer with another pane never asks, since nothing is lost.
+func (tab *editorTab) AskBufferDestroy(do func()) {
+	if !tab.IsUnsaved() || tab.IsLinked() {
+		glib.IdleAdd(do)
+		return
+	}
+
+	askDiscard(tab.tabs.ctx, func() {
+		tab.DiscardChanges()
+		do()
+	})
+}
+
+// OpenPath implements editor.Controller.
+func (tab *editorTab) OpenPath(path string) {
+	tab.tabs.page.Load(tab.ResolvePath(path))
+}
+
+// ResolvePath implements editor.Controller. Relative paths are relative to the
+// opened folder, or to the tab's file if no folder is open.
+func (tab *editorTab) ResolvePath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+
+	if !filepath.IsAbs(path) {
+		root := tab.tabs.page.Files.Path()
+		if root == "" {
+			root = filepath.Dir(tab.Path())
+		}
+		path = filepath.Join(root, path)
+	}
+
+	return path
+}
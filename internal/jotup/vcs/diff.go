@@ -0,0 +1,215 @@
+package vcs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffTimeout bounds the time spent diffing, since it's done while typing.
+const diffTimeout = time.Second
+
+// HunkKind is the kind of change of a Hunk.
+type HunkKind uint8
+
+const (
+	HunkAdded HunkKind = iota
+	HunkModified
+	HunkDeleted
+)
+
+// Hunk is a contiguous change between two versions of a file. Lines are
+// counted from 0.
+type Hunk struct {
+	Kind HunkKind
+	// Start and Lines are the changed lines in the new version. Lines is 0 for
+	// deletions, in which case Start is the line after the deleted ones.
+	Start int
+	Lines int
+	// Old is the original text of the changed lines, including the trailing
+	// new line.
+	Old string
+}
+
+// Contains returns true if the given line belongs to the hunk. Deletions
+// belong to the line before them, or the first line if there's none.
+func (h Hunk) Contains(line int) bool {
+	if h.Kind == HunkDeleted {
+		return line == h.MarkerLine()
+	}
+	return h.Start <= line && line < h.Start+h.Lines
+}
+
+// MarkerLine returns the line that a deletion is shown on.
+func (h Hunk) MarkerLine() int {
+	if h.Start > 0 {
+		return h.Start - 1
+	}
+	return 0
+}
+
+// DiffLines returns the changes from the old text to the new text.
+func DiffLines(old, new string) []Hunk {
+	var hunks []Hunk
+	var line int
+
+	diffs := diff.DoWithTimeout(old, new, diffTimeout)
+
+	for i := 0; i < len(diffs); i++ {
+		d := diffs[i]
+		n := countLines(d.Text)
+
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			line += n
+
+		case diffmatchpatch.DiffDelete:
+			hunk := Hunk{Kind: HunkDeleted, Start: line, Old: d.Text}
+			// A deletion followed by an insertion is a modification.
+			if i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert {
+				i++
+				hunk.Kind = HunkModified
+				hunk.Lines = countLines(diffs[i].Text)
+			}
+			line += hunk.Lines
+			hunks = append(hunks, hunk)
+
+		case diffmatchpatch.DiffInsert:
+			hunks = append(hunks, Hunk{Kind: HunkAdded, Start: line, Lines: n})
+			line += n
+		}
+	}
+
+	return hunks
+}
+
+// SideKind is the kind of a SideLine.
+type SideKind uint8
+
+const (
+	SideEqual SideKind = iota
+	SideChanged
+	// SideFiller is an empty line padding the shorter side of a change.
+	SideFiller
+)
+
+// SideLine is a line of one side of a side-by-side diff.
+type SideLine struct {
+	Kind SideKind
+	// Text is the line without its trailing new line.
+	Text string
+}
+
+// SideBySide returns the lines of the old and the new text aligned for
+// showing them next to each other. Both sides have the same number of lines.
+func SideBySide(old, new string) (left, right []SideLine) {
+	diffs := diff.DoWithTimeout(old, new, diffTimeout)
+
+	for i := 0; i < len(diffs); i++ {
+		d := diffs[i]
+
+		var removed, added []string
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			for _, line := range splitLines(d.Text) {
+				left = append(left, SideLine{Kind: SideEqual, Text: line})
+				right = append(right, SideLine{Kind: SideEqual, Text: line})
+			}
+			continue
+		case diffmatchpatch.DiffDelete:
+			removed = splitLines(d.Text)
+			if i+1 < len(diffs) && diffs[i+1].Type == diffmatchpatch.DiffInsert {
+				i++
+				added = splitLines(diffs[i].Text)
+			}
+		case diffmatchpatch.DiffInsert:
+			added = splitLines(d.Text)
+		}
+
+		left = appendSide(left, removed, len(added))
+		right = appendSide(right, added, len(removed))
+	}
+
+	return left, right
+}
+
+// appendSide appends the changed lines, padded to the number of lines on the
+// other side.
+func appendSide(side []SideLine, lines []string, other int) []SideLine {
+	for _, line := range lines {
+		side = append(side, SideLine{Kind: SideChanged, Text: line})
+	}
+	for n := len(lines); n < other; n++ {
+		side = append(side, SideLine{Kind: SideFiller})
+	}
+	return side
+}
+
+// splitLines splits s into lines without their trailing new lines.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	return lines
+}
+
+// countLines counts the lines in s. A last line without a trailing new line
+// is counted as well.
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
+
+// ErrNotCommitted is returned by HeadContents if the file isn't in HEAD.
+var ErrNotCommitted = errors.New("file is not committed")
+
+// HeadContents returns the contents of the file at the given absolute path as
+// of HEAD.
+func (r *Repository) HeadContents(path string) (string, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	file, err := commit.File(rel)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return "", ErrNotCommitted
+		}
+		return "", err
+	}
+
+	return file.Contents()
+}
+
+// relPath returns the slash-separated path of the file relative to the
+// worktree, as used by git.
+func (r *Repository) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(r.Root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
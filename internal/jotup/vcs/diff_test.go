@@ -0,0 +1,149 @@
+package vcs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		hunks []Hunk
+	}{
+		{
+			name: "same",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+		},
+		{
+			name:  "added",
+			old:   "a\nc\n",
+			new:   "a\nb1\nb2\nc\n",
+			hunks: []Hunk{{Kind: HunkAdded, Start: 1, Lines: 2}},
+		},
+		{
+			name:  "modified",
+			old:   "a\nb\nc\n",
+			new:   "a\nB\nc\n",
+			hunks: []Hunk{{Kind: HunkModified, Start: 1, Lines: 1, Old: "b\n"}},
+		},
+		{
+			name:  "deleted",
+			old:   "a\nb\nc\n",
+			new:   "a\nc\n",
+			hunks: []Hunk{{Kind: HunkDeleted, Start: 1, Old: "b\n"}},
+		},
+		{
+			name:  "deleted first",
+			old:   "a\nb\n",
+			new:   "b\n",
+			hunks: []Hunk{{Kind: HunkDeleted, Start: 0, Old: "a\n"}},
+		},
+		{
+			name: "several",
+			old:  "a\nb\nc\nd\n",
+			new:  "x\na\nc\nD\n",
+			hunks: []Hunk{
+				{Kind: HunkAdded, Start: 0, Lines: 1},
+				{Kind: HunkDeleted, Start: 2, Old: "b\n"},
+				{Kind: HunkModified, Start: 3, Lines: 1, Old: "d\n"},
+			},
+		},
+		{
+			// The last line changed by gaining a new line.
+			name:  "no trailing new line",
+			old:   "a",
+			new:   "a\nb",
+			hunks: []Hunk{{Kind: HunkModified, Start: 0, Lines: 2, Old: "a"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hunks := DiffLines(test.old, test.new)
+			if !reflect.DeepEqual(hunks, test.hunks) {
+				t.Errorf("expected hunks %+v, got %+v", test.hunks, hunks)
+			}
+		})
+	}
+}
+
+func TestHunkContains(t *testing.T) {
+	modified := Hunk{Kind: HunkModified, Start: 2, Lines: 2}
+	for line, contains := range []bool{false, false, true, true, false} {
+		if modified.Contains(line) != contains {
+			t.Errorf("modified hunk: Contains(%d) != %v", line, contains)
+		}
+	}
+
+	deleted := Hunk{Kind: HunkDeleted, Start: 2}
+	for line, contains := range []bool{false, true, false} {
+		if deleted.Contains(line) != contains {
+			t.Errorf("deleted hunk: Contains(%d) != %v", line, contains)
+		}
+	}
+
+	if first := (Hunk{Kind: HunkDeleted, Start: 0}); !first.Contains(0) {
+		t.Error("deletion of the first lines isn't shown on line 0")
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	eq := func(text string) SideLine { return SideLine{Kind: SideEqual, Text: text} }
+	ch := func(text string) SideLine { return SideLine{Kind: SideChanged, Text: text} }
+	fill := SideLine{Kind: SideFiller}
+
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		left  []SideLine
+		right []SideLine
+	}{
+		{
+			name:  "same",
+			old:   "a\nb\n",
+			new:   "a\nb\n",
+			left:  []SideLine{eq("a"), eq("b")},
+			right: []SideLine{eq("a"), eq("b")},
+		},
+		{
+			name:  "added",
+			old:   "a\n",
+			new:   "a\nb\n",
+			left:  []SideLine{eq("a"), fill},
+			right: []SideLine{eq("a"), ch("b")},
+		},
+		{
+			name:  "deleted",
+			old:   "a\nb\nc\n",
+			new:   "a\nc\n",
+			left:  []SideLine{eq("a"), ch("b"), eq("c")},
+			right: []SideLine{eq("a"), fill, eq("c")},
+		},
+		{
+			name:  "modified into more lines",
+			old:   "a\nb\nc\n",
+			new:   "a\nB1\nB2\nc\n",
+			left:  []SideLine{eq("a"), ch("b"), fill, eq("c")},
+			right: []SideLine{eq("a"), ch("B1"), ch("B2"), eq("c")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			left, right := SideBySide(test.old, test.new)
+			if len(left) != len(right) {
+				t.Fatalf("sides differ in length: %d and %d", len(left), len(right))
+			}
+			if !reflect.DeepEqual(left, test.left) {
+				t.Errorf("expected left side %+v, got %+v", test.left, left)
+			}
+			if !reflect.DeepEqual(right, test.right) {
+				t.Errorf("expected right side %+v, got %+v", test.right, right)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package vcs
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Revision is a commit that changed a file.
+type Revision struct {
+	Hash    plumbing.Hash
+	Author  string
+	When    time.Time
+	Message string
+}
+
+// ShortHash returns the abbreviated hash of the commit.
+func (r Revision) ShortHash() string {
+	return r.Hash.String()[:7]
+}
+
+// Summary returns the first line of the commit message.
+func (r Revision) Summary() string {
+	summary := strings.TrimSpace(r.Message)
+	if i := strings.IndexByte(summary, '\n'); i >= 0 {
+		summary = summary[:i]
+	}
+	return summary
+}
+
+// FileHistory returns the commits reachable from HEAD that changed the file at
+// the given absolute path, newest first. Renames aren't followed.
+func (r *Repository) FileHistory(path string) ([]Revision, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// No commits yet.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return r.fileLog(head.Hash(), rel)
+}
+
+func (r *Repository) fileLog(from plumbing.Hash, rel string) ([]Revision, error) {
+	commits, err := r.Log(&git.LogOptions{
+		From:     from,
+		Order:    git.LogOrderCommitterTime,
+		FileName: &rel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []Revision
+	err = commits.ForEach(func(c *object.Commit) error {
+		revisions = append(revisions, Revision{
+			Hash:    c.Hash,
+			Author:  c.Author.Name,
+			When:    c.Author.When,
+			Message: c.Message,
+		})
+		return nil
+	})
+
+	return revisions, err
+}
+
+// FileAt returns the contents of the file at the given absolute path as of the
+// given commit. ErrNotCommitted is returned if the file isn't in the commit,
+// such as when the commit deleted it.
+func (r *Repository) FileAt(hash plumbing.Hash, path string) (string, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := commit.File(rel)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return "", ErrNotCommitted
+		}
+		return "", err
+	}
+
+	return file.Contents()
+}
+
+// BlameLine is a line of a file along with the commit that last changed it.
+type BlameLine struct {
+	Revision
+	Text string
+}
+
+// blameKey identifies the blame of a file as of a commit.
+type blameKey struct {
+	hash plumbing.Hash
+	rel  string
+}
+
+// blameState is the blame of a file as of a commit that changed it, from which
+// the blame as of later commits is worked out.
+type blameState struct {
+	lines    []BlameLine
+	contents string
+	exists   bool
+}
+
+// Blame returns the lines of the file at the given absolute path as of the
+// given commit, each with the commit that last changed it. The blame of every
+// commit is cached, so going through the history of a file only diffs each
+// commit once.
+func (r *Repository) Blame(hash plumbing.Hash, path string) ([]BlameLine, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := r.fileLog(hash, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the newest commit that was blamed before, if any.
+	var state blameState
+	start := len(revisions) - 1
+
+	for i, rev := range revisions {
+		if cached, ok := r.cachedBlame(blameKey{rev.Hash, rel}); ok {
+			state = cached
+			start = i - 1
+			break
+		}
+	}
+
+	// Replay the history from there, carrying unchanged lines over.
+	for i := start; i >= 0; i-- {
+		rev := revisions[i]
+
+		next, err := r.FileAt(rev.Hash, path)
+		switch {
+		case errors.Is(err, ErrNotCommitted):
+			// Deleted, so anything added later is new.
+			state = blameState{}
+		case err != nil:
+			return nil, err
+		default:
+			state = blameState{
+				lines:    blameNext(state.lines, state.contents, next, rev),
+				contents: next,
+				exists:   true,
+			}
+		}
+
+		r.cacheBlame(blameKey{rev.Hash, rel}, state)
+	}
+
+	if !state.exists {
+		return nil, ErrNotCommitted
+	}
+
+	return state.lines, nil
+}
+
+func (r *Repository) cachedBlame(key blameKey) (blameState, bool) {
+	r.blameMu.Lock()
+	defer r.blameMu.Unlock()
+
+	state, ok := r.blames[key]
+	return state, ok
+}
+
+func (r *Repository) cacheBlame(key blameKey, state blameState) {
+	r.blameMu.Lock()
+	defer r.blameMu.Unlock()
+
+	if r.blames == nil {
+		r.blames = make(map[blameKey]blameState)
+	}
+	r.blames[key] = state
+}
+
+// blameNext returns the lines of the next contents, attributing the lines that
+// changed since the previous contents to rev.
+func blameNext(prev []BlameLine, contents, next string, rev Revision) []BlameLine {
+	lines := make([]BlameLine, 0, countLines(next))
+	var line int
+
+	for _, d := range diff.DoWithTimeout(contents, next, diffTimeout) {
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			n := countLines(d.Text)
+			lines = append(lines, prev[line:line+n]...)
+			line += n
+		case diffmatchpatch.DiffDelete:
+			line += countLines(d.Text)
+		case diffmatchpatch.DiffInsert:
+			for _, text := range splitLines(d.Text) {
+				lines = append(lines, BlameLine{Revision: rev, Text: text})
+			}
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,135 @@
+package vcs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// blameTexts returns the text of each line along with the summary of the commit
+// that last changed it.
+func blameTexts(lines []BlameLine) [][2]string {
+	texts := make([][2]string, len(lines))
+	for i, line := range lines {
+		texts[i] = [2]string{line.Text, line.Summary()}
+	}
+	return texts
+}
+
+func TestBlameNext(t *testing.T) {
+	rev1 := Revision{Message: "one"}
+	rev2 := Revision{Message: "two"}
+
+	first := blameNext(nil, "", "a\nb\nc\n", rev1)
+
+	tests := []struct {
+		name  string
+		next  string
+		blame [][2]string
+	}{
+		{
+			name:  "unchanged",
+			next:  "a\nb\nc\n",
+			blame: [][2]string{{"a", "one"}, {"b", "one"}, {"c", "one"}},
+		},
+		{
+			name:  "inserted",
+			next:  "a\nx\nb\nc\n",
+			blame: [][2]string{{"a", "one"}, {"x", "two"}, {"b", "one"}, {"c", "one"}},
+		},
+		{
+			name:  "modified",
+			next:  "a\nB\nc\n",
+			blame: [][2]string{{"a", "one"}, {"B", "two"}, {"c", "one"}},
+		},
+		{
+			name:  "deleted",
+			next:  "a\nc\n",
+			blame: [][2]string{{"a", "one"}, {"c", "one"}},
+		},
+		{
+			name:  "emptied",
+			next:  "",
+			blame: [][2]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := blameNext(first, "a\nb\nc\n", test.next, rev2)
+			if blame := blameTexts(lines); !reflect.DeepEqual(blame, test.blame) {
+				t.Errorf("expected blame %q, got %q", test.blame, blame)
+			}
+		})
+	}
+}
+
+func TestBlame(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{"notes.md": "a\nb\n"})
+	path := repoPath(repo, "notes.md")
+
+	writeFile(t, repo, "notes.md", "a\nB\nc\n")
+	commitAll(t, repo, "Change b")
+
+	writeFile(t, repo, "other.md", "x\n")
+	commitAll(t, repo, "Unrelated")
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][2]string{
+		{"a", "Initial commit"},
+		{"B", "Change b"},
+		{"c", "Change b"},
+	}
+
+	lines, err := repo.Blame(head.Hash(), path)
+	if err != nil {
+		t.Fatal("cannot blame:", err)
+	}
+	if blame := blameTexts(lines); !reflect.DeepEqual(blame, expected) {
+		t.Errorf("expected blame %q, got %q", expected, blame)
+	}
+
+	// The cached blame must give the same result.
+	lines, err = repo.Blame(head.Hash(), path)
+	if err != nil {
+		t.Fatal("cannot blame again:", err)
+	}
+	if blame := blameTexts(lines); !reflect.DeepEqual(blame, expected) {
+		t.Errorf("expected cached blame %q, got %q", expected, blame)
+	}
+
+	// Deleting the file and adding it back starts over.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	commitAll(t, repo, "Delete notes")
+
+	deleted, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Blame(deleted.Hash(), path); err != ErrNotCommitted {
+		t.Errorf("expected ErrNotCommitted for a deleted file, got %v", err)
+	}
+
+	writeFile(t, repo, "notes.md", "a\n")
+	commitAll(t, repo, "Restore notes")
+
+	restored, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err = repo.Blame(restored.Hash(), path)
+	if err != nil {
+		t.Fatal("cannot blame the restored file:", err)
+	}
+	if blame := blameTexts(lines); !reflect.DeepEqual(blame, [][2]string{{"a", "Restore notes"}}) {
+		t.Errorf("restored file has blame %q", blame)
+	}
+}
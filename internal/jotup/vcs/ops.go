@@ -0,0 +1,196 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DefaultRemote is the remote that Fetch, Pull and Push use.
+const DefaultRemote = "origin"
+
+// ErrUpToDate is returned by Fetch, Pull and Push if there was nothing to do.
+var ErrUpToDate = git.NoErrAlreadyUpToDate
+
+// Change is a changed file in the worktree.
+type Change struct {
+	// Path is the absolute path to the file.
+	Path     string
+	Staging  git.StatusCode
+	Worktree git.StatusCode
+}
+
+// IsStaged returns true if the file has changes in the index.
+func (c Change) IsStaged() bool {
+	return c.Staging != git.Unmodified && c.Staging != git.Untracked
+}
+
+// IsUnstaged returns true if the file has changes that aren't in the index.
+func (c Change) IsUnstaged() bool {
+	return c.Worktree != git.Unmodified
+}
+
+// Changes returns all changed files sorted by path.
+func (r *Repository) Changes() ([]Change, error) {
+	status, err := r.Worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0, len(status))
+	for path, s := range status {
+		if s.Staging == git.Unmodified && s.Worktree == git.Unmodified {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Path:     filepath.Join(r.Root, filepath.FromSlash(path)),
+			Staging:  s.Staging,
+			Worktree: s.Worktree,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// BranchName returns the short name of the current branch, or the abbreviated hash
+// if HEAD is detached.
+func (r *Repository) BranchName() (string, error) {
+	head, err := r.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// No commits yet.
+			return "", nil
+		}
+		return "", err
+	}
+
+	if head.Name().IsBranch() {
+		return head.Name().Short(), nil
+	}
+
+	return head.Hash().String()[:7], nil
+}
+
+// Stage adds the current state of the file at the given absolute path to the
+// index.
+func (r *Repository) Stage(change Change) error {
+	rel, err := r.relPath(change.Path)
+	if err != nil {
+		return err
+	}
+
+	if change.Worktree == git.Deleted {
+		_, err = r.Worktree.Remove(rel)
+	} else {
+		_, err = r.Worktree.Add(rel)
+	}
+
+	return err
+}
+
+// Unstage resets the file at the given absolute path in the index to its state
+// at HEAD, keeping the changes in the worktree.
+func (r *Repository) Unstage(change Change) error {
+	rel, err := r.relPath(change.Path)
+	if err != nil {
+		return err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	entry, err := r.headEntry(rel)
+	if err != nil {
+		return err
+	}
+
+	if entry == nil {
+		// Not in HEAD, so it's a newly added file.
+		if _, err := idx.Remove(rel); err != nil {
+			return err
+		}
+	} else {
+		e, err := idx.Entry(rel)
+		if err != nil {
+			// Staged for removal, so add it back.
+			e = idx.Add(rel)
+		}
+		e.Hash = entry.Hash
+		e.Mode = entry.Mode
+	}
+
+	return r.Storer.SetIndex(idx)
+}
+
+// headEntry returns the tree entry of the file at HEAD, or nil if it's not
+// there.
+func (r *Repository) headEntry(rel string) (*object.TreeEntry, error) {
+	head, err := r.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := tree.FindEntry(rel)
+	if err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Commit commits the index with the given message. The author is taken from
+// the git configuration.
+func (r *Repository) Commit(message string) error {
+	_, err := r.Worktree.Commit(message, &git.CommitOptions{})
+	return err
+}
+
+// Fetch fetches from the default remote.
+func (r *Repository) Fetch(ctx context.Context) error {
+	return r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: DefaultRemote,
+	})
+}
+
+// Pull fetches from the default remote and merges into the current branch. Only
+// fast-forwards are supported.
+func (r *Repository) Pull(ctx context.Context) error {
+	return r.Worktree.PullContext(ctx, &git.PullOptions{
+		RemoteName: DefaultRemote,
+	})
+}
+
+// Push pushes the current branch to the default remote.
+func (r *Repository) Push(ctx context.Context) error {
+	return r.PushContext(ctx, &git.PushOptions{
+		RemoteName: DefaultRemote,
+	})
+}
@@ -0,0 +1,204 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// changeMap returns the changes of the repository keyed by their paths
+// relative to the worktree.
+func changeMap(t *testing.T, repo *Repository) map[string]Change {
+	t.Helper()
+
+	changes, err := repo.Changes()
+	if err != nil {
+		t.Fatal("cannot get changes:", err)
+	}
+
+	m := make(map[string]Change, len(changes))
+	for _, change := range changes {
+		rel, err := repo.relPath(change.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[rel] = change
+	}
+	return m
+}
+
+// makeChanges modifies, adds and deletes a file of a repository created with
+// opsFiles.
+func makeChanges(t *testing.T, repo *Repository) {
+	t.Helper()
+
+	writeFile(t, repo, "modified.md", "new\n")
+	writeFile(t, repo, "added.md", "added\n")
+	if err := os.Remove(repoPath(repo, "deleted.md")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var opsFiles = map[string]string{
+	"modified.md": "old\n",
+	"deleted.md":  "deleted\n",
+}
+
+func TestStageUnstage(t *testing.T) {
+	repo := newTestRepo(t, opsFiles)
+	makeChanges(t, repo)
+
+	for _, change := range changeMap(t, repo) {
+		if change.IsStaged() || !change.IsUnstaged() {
+			t.Fatalf("%s: expected an unstaged change, got %+v", change.Path, change)
+		}
+		if err := repo.Stage(change); err != nil {
+			t.Fatalf("cannot stage %s: %v", change.Path, err)
+		}
+	}
+
+	staged := changeMap(t, repo)
+	expected := map[string]git.StatusCode{
+		"modified.md": git.Modified,
+		"added.md":    git.Added,
+		"deleted.md":  git.Deleted,
+	}
+
+	if len(staged) != len(expected) {
+		t.Fatalf("expected %d staged changes, got %d", len(expected), len(staged))
+	}
+
+	for rel, code := range expected {
+		change := staged[rel]
+		if change.Staging != code || change.IsUnstaged() {
+			t.Errorf("%s: expected staging %q only, got %+v", rel, code, change)
+		}
+		if err := repo.Unstage(change); err != nil {
+			t.Fatalf("cannot unstage %s: %v", rel, err)
+		}
+	}
+
+	for rel, change := range changeMap(t, repo) {
+		if change.IsStaged() {
+			t.Errorf("%s: still staged after Unstage: %+v", rel, change)
+		}
+	}
+
+	// The worktree must be left alone.
+	b, err := os.ReadFile(repoPath(repo, "modified.md"))
+	if err != nil || string(b) != "new\n" {
+		t.Errorf("unstaging changed the worktree: %q, %v", b, err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	repo := newTestRepo(t, opsFiles)
+	makeChanges(t, repo)
+
+	for _, change := range changeMap(t, repo) {
+		if err := repo.Stage(change); err != nil {
+			t.Fatalf("cannot stage %s: %v", change.Path, err)
+		}
+	}
+
+	if err := repo.Commit("Change things"); err != nil {
+		t.Fatal("cannot commit:", err)
+	}
+
+	if changes := changeMap(t, repo); len(changes) > 0 {
+		t.Errorf("expected no changes after committing, got %v", changes)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commit.Message != "Change things" {
+		t.Errorf("unexpected commit message %q", commit.Message)
+	}
+	if commit.Author.Email != "test@example.com" {
+		t.Errorf("author not taken from the config: %q", commit.Author.Email)
+	}
+}
+
+// newRemote creates a bare repository and adds it as the default remote of the
+// given repository.
+func newRemote(t *testing.T, repo *Repository) string {
+	t.Helper()
+
+	// The file transport runs git-upload-pack and git-receive-pack.
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := filepath.Join(t.TempDir(), "remote.git")
+	if _, err := git.PlainInit(dir, true); err != nil {
+		t.Fatal("cannot init remote:", err)
+	}
+
+	_, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemote,
+		URLs: []string{dir},
+	})
+	if err != nil {
+		t.Fatal("cannot add remote:", err)
+	}
+
+	return dir
+}
+
+func TestPushPull(t *testing.T) {
+	ctx := context.Background()
+
+	repo := newTestRepo(t, map[string]string{"a.md": "a\n"})
+	remote := newRemote(t, repo)
+
+	if err := repo.Push(ctx); err != nil {
+		t.Fatal("cannot push:", err)
+	}
+	if err := repo.Push(ctx); !errors.Is(err, ErrUpToDate) {
+		t.Fatal("expected ErrUpToDate pushing again, got", err)
+	}
+
+	// Make a change from another clone.
+	dir := t.TempDir()
+	if _, err := git.PlainClone(dir, false, &git.CloneOptions{URL: remote}); err != nil {
+		t.Fatal("cannot clone:", err)
+	}
+
+	other, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, other, "b.md", "b\n")
+	commitAll(t, other, "Add b")
+
+	if err := other.Push(ctx); err != nil {
+		t.Fatal("cannot push from the clone:", err)
+	}
+
+	if err := repo.Pull(ctx); err != nil {
+		t.Fatal("cannot pull:", err)
+	}
+	if err := repo.Pull(ctx); !errors.Is(err, ErrUpToDate) {
+		t.Fatal("expected ErrUpToDate pulling again, got", err)
+	}
+
+	b, err := os.ReadFile(repoPath(repo, "b.md"))
+	if err != nil || string(b) != "b\n" {
+		t.Errorf("pulled file has %q, %v", b, err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package vcs provides the git integration of the workspace.
+package vcs
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+)
+
+// FileStatus is the git status of a file. Statuses are ordered by importance,
+// so a directory shows the greatest status of the files within.
+type FileStatus uint8
+
+const (
+	Unmodified FileStatus = iota
+	Untracked
+	Added
+	Modified
+	Conflicted
+)
+
+// Marker returns the letter shown next to a file with the status.
+func (s FileStatus) Marker() string {
+	switch s {
+	case Untracked:
+		return "U"
+	case Added:
+		return "A"
+	case Modified:
+		return "M"
+	case Conflicted:
+		return "C"
+	default:
+		return ""
+	}
+}
+
+// Color returns the color of the status marker.
+func (s FileStatus) Color() string {
+	switch s {
+	case Untracked, Added:
+		return "#26a269"
+	case Modified:
+		return "#e5a50a"
+	case Conflicted:
+		return "#e01b24"
+	default:
+		return ""
+	}
+}
+
+// Repository is a git repository with a worktree.
+type Repository struct {
+	*git.Repository
+	Worktree *git.Worktree
+	// Root is the absolute path to the worktree.
+	Root string
+
+	blameMu sync.Mutex
+	blames  map[blameKey]blameState
+}
+
+// Open opens the git repository containing the given path.
+// git.ErrRepositoryNotExists is returned if there's none.
+func Open(path string) (*Repository, error) {
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		Repository: repo,
+		Worktree:   wt,
+		Root:       wt.Filesystem.Root(),
+	}, nil
+}
+
+// Status returns the status of all changed files keyed by their absolute
+// paths. Deleted files are included as modified.
+func (r *Repository) Status() (map[string]FileStatus, error) {
+	status, err := r.Worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]FileStatus, len(status))
+	for path, s := range status {
+		if st := fileStatus(s); st != Unmodified {
+			files[filepath.Join(r.Root, filepath.FromSlash(path))] = st
+		}
+	}
+
+	return files, nil
+}
+
+// PathStatus returns the status of the file at the given absolute path. Unlike
+// Status, it doesn't walk the whole worktree, so it's cheap enough to call on
+// every save.
+func (r *Repository) PathStatus(path string) (FileStatus, error) {
+	rel, err := r.relPath(path)
+	if err != nil {
+		return Unmodified, err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return Unmodified, err
+	}
+
+	var entry *index.Entry
+	for _, e := range idx.Entries {
+		if e.Name != rel {
+			continue
+		}
+		// Unmerged files have an entry for each side with a nonzero stage.
+		if e.Stage > 0 {
+			return Conflicted, nil
+		}
+		entry = e
+	}
+
+	head, err := r.headEntry(rel)
+	if err != nil {
+		return Unmodified, err
+	}
+
+	if entry == nil {
+		if head != nil {
+			// Staged for removal.
+			return Modified, nil
+		}
+
+		if _, err := os.Lstat(path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return Unmodified, nil
+			}
+			return Unmodified, err
+		}
+
+		if r.isIgnored(rel) {
+			return Unmodified, nil
+		}
+		return Untracked, nil
+	}
+
+	switch {
+	case head == nil:
+		return Added, nil
+	case head.Hash != entry.Hash:
+		return Modified, nil
+	}
+
+	changed, err := worktreeChanged(path, entry)
+	if err != nil {
+		return Unmodified, err
+	}
+	if changed {
+		return Modified, nil
+	}
+
+	return Unmodified, nil
+}
+
+// worktreeChanged returns true if the file at the given absolute path differs
+// from its index entry.
+func worktreeChanged(path string, entry *index.Entry) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	var data []byte
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false, err
+		}
+		data = []byte(target)
+	} else {
+		if info.Size() != int64(entry.Size) {
+			return true, nil
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return false, err
+		}
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, data) != entry.Hash, nil
+}
+
+// isIgnored returns true if the file with the given slash-separated path
+// relative to the worktree is ignored. Only the ignore files along its path are
+// read.
+func (r *Repository) isIgnored(rel string) bool {
+	parts := strings.Split(rel, "/")
+
+	patterns := append([]gitignore.Pattern(nil), r.Worktree.Excludes...)
+	patterns = append(patterns, readPatterns(filepath.Join(r.Root, ".git", "info", "exclude"), nil)...)
+
+	for i := 0; i < len(parts); i++ {
+		dir := filepath.Join(r.Root, filepath.Join(parts[:i]...))
+		patterns = append(patterns, readPatterns(filepath.Join(dir, ".gitignore"), parts[:i])...)
+	}
+
+	return gitignore.NewMatcher(patterns).Match(parts, false)
+}
+
+// readPatterns reads the patterns of the given ignore file. A missing or
+// unreadable file has no patterns.
+func readPatterns(path string, domain []string) []gitignore.Pattern {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "#") && strings.TrimSpace(line) != "" {
+			patterns = append(patterns, gitignore.ParsePattern(line, domain))
+		}
+	}
+
+	return patterns
+}
+
+func fileStatus(s *git.FileStatus) FileStatus {
+	switch {
+	case s.Staging == git.UpdatedButUnmerged || s.Worktree == git.UpdatedButUnmerged:
+		return Conflicted
+	case s.Worktree == git.Untracked:
+		return Untracked
+	case s.Staging == git.Added:
+		return Added
+	case s.Staging != git.Unmodified || s.Worktree != git.Unmodified:
+		return Modified
+	default:
+		return Unmodified
+	}
+}
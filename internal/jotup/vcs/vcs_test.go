@@ -0,0 +1,161 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newTestRepo creates a repository in a temporary directory with an initial
+// commit of the given files.
+func newTestRepo(t *testing.T, files map[string]string) *Repository {
+	t.Helper()
+
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal("cannot init repository:", err)
+	}
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatal("cannot open repository:", err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal("cannot get config:", err)
+	}
+	cfg.User.Name = "Test"
+	cfg.User.Email = "test@example.com"
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal("cannot set config:", err)
+	}
+
+	for name, contents := range files {
+		writeFile(t, repo, name, contents)
+	}
+	commitAll(t, repo, "Initial commit")
+
+	return repo
+}
+
+// writeFile writes the file with the given slash-separated path relative to
+// the worktree, creating its directories.
+func writeFile(t *testing.T, repo *Repository, name, contents string) {
+	t.Helper()
+
+	path := repoPath(repo, name)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// commitAll stages all changes and commits them.
+func commitAll(t *testing.T, repo *Repository, message string) {
+	t.Helper()
+
+	status, err := repo.Worktree.Status()
+	if err != nil {
+		t.Fatal("cannot get status:", err)
+	}
+
+	for path, s := range status {
+		if s.Worktree == git.Deleted {
+			_, err = repo.Worktree.Remove(path)
+		} else {
+			_, err = repo.Worktree.Add(path)
+		}
+		if err != nil {
+			t.Fatalf("cannot stage %s: %v", path, err)
+		}
+	}
+
+	_, err = repo.Worktree.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{
+			Name:  "Test",
+			Email: "test@example.com",
+			When:  time.Now(),
+		},
+	})
+	if err != nil {
+		t.Fatal("cannot commit:", err)
+	}
+}
+
+func repoPath(repo *Repository, name string) string {
+	return filepath.Join(repo.Root, filepath.FromSlash(name))
+}
+
+func TestPathStatus(t *testing.T) {
+	repo := newTestRepo(t, map[string]string{
+		".gitignore":   "*.log\nbuild/\n",
+		"modified.md":  "a\n",
+		"same.md":      "b\n",
+		"removed.md":   "c\n",
+		"deleted.md":   "d\n",
+		"notes/sub.md": "e\n",
+	})
+
+	writeFile(t, repo, "modified.md", "a\nb\n")
+	writeFile(t, repo, "notes/sub.md", "f\n")
+	writeFile(t, repo, "untracked.md", "g\n")
+	writeFile(t, repo, "debug.log", "h\n")
+	writeFile(t, repo, "build/out.md", "i\n")
+	writeFile(t, repo, "added.md", "j\n")
+
+	if _, err := repo.Worktree.Add("added.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Worktree.Remove("removed.md"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(repoPath(repo, "deleted.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := repo.Status()
+	if err != nil {
+		t.Fatal("cannot get status:", err)
+	}
+
+	tests := []struct {
+		name   string
+		status FileStatus
+	}{
+		{"modified.md", Modified},
+		{"same.md", Unmodified},
+		{"removed.md", Modified},
+		{"deleted.md", Modified},
+		{"notes/sub.md", Modified},
+		{"untracked.md", Untracked},
+		{"debug.log", Unmodified},
+		{"build/out.md", Unmodified},
+		{"added.md", Added},
+		{"missing.md", Unmodified},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := repoPath(repo, test.name)
+
+			got, err := repo.PathStatus(path)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if got != test.status {
+				t.Errorf("expected status %d, got %d", test.status, got)
+			}
+			// It must agree with the full status.
+			if got != status[path] {
+				t.Errorf("Status has %d, PathStatus has %d", status[path], got)
+			}
+		})
+	}
+}
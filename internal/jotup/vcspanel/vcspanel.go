@@ -0,0 +1,594 @@
+// Package vcspanel provides the version control sidebar page, which stages and
+// commits changes and syncs them with the remote.
+package vcspanel
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diamondburned/gotk4/pkg/gtk/v4"
+	"github.com/diamondburned/gotk4/pkg/pango"
+	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/gotkit/gtkutil/cssutil"
+	"github.com/diamondburned/jotup/internal/jotup/components/toast"
+	"github.com/diamondburned/jotup/internal/jotup/vcs"
+	"github.com/go-git/go-git/v5"
+)
+
+// Panel is the version control sidebar page.
+type Panel struct {
+	*gtk.Box
+	Actions *gtk.ActionBar
+	Toast   *toast.Toast
+
+	stack    *gtk.Stack
+	empty    *gtk.Label
+	content  *gtk.ScrolledWindow
+	branch   *gtk.Label
+	message  *gtk.TextView
+	commit   *gtk.Button
+	staged   changeList
+	unstaged changeList
+
+	spinbox *gtk.Revealer
+	spinner *gtk.Spinner
+
+	ctx  context.Context
+	root string
+	repo *vcs.Repository
+	mu   sync.Mutex // guards repo operations off the main thread
+
+	activated []func(path string)
+	changed   []func()
+}
+
+// changeList is a list of either staged or unstaged changes.
+type changeList struct {
+	*gtk.Box
+	Label   *gtk.Label
+	List    *gtk.ListBox
+	changes []vcs.Change
+}
+
+var panelCSS = cssutil.Applier("vcspanel", `
+	.vcspanel-section {
+		margin: 6px 6px 2px 6px;
+	}
+	.vcspanel-message {
+		padding: 4px;
+		min-height: 4em;
+	}
+	.vcspanel-commit {
+		margin: 6px;
+	}
+	.vcspanel-row {
+		padding: 2px 6px;
+	}
+	.vcspanel-row button {
+		padding: 0;
+		min-width:  24px;
+		min-height: 24px;
+	}
+	actionbar spinner.vcspanel-loading {
+		margin: 0 6px;
+	}
+`)
+
+// NewPanel creates a new Panel.
+func NewPanel(ctx context.Context) *Panel {
+	p := Panel{ctx: ctx}
+
+	p.branch = gtk.NewLabel("")
+	p.branch.SetXAlign(0)
+	p.branch.SetHExpand(true)
+	p.branch.SetEllipsize(pango.EllipsizeEnd)
+	p.branch.AddCSSClass("dim-label")
+	p.branch.AddCSSClass("vcspanel-section")
+
+	p.message = gtk.NewTextView()
+	p.message.AddCSSClass("vcspanel-message")
+	p.message.SetWrapMode(gtk.WrapWordChar)
+	p.message.SetAcceptsTab(false)
+	p.message.Buffer().ConnectChanged(p.updateCommit)
+	gtkutil.BindKeys(p.message, map[string]func() bool{
+		"<Ctrl>Return": func() bool {
+			if p.commit.Sensitive() {
+				p.Commit()
+			}
+			return true
+		},
+	})
+
+	messageScroll := gtk.NewScrolledWindow()
+	messageScroll.AddCSSClass("vcspanel-section")
+	messageScroll.SetHasFrame(true)
+	messageScroll.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	messageScroll.SetMaxContentHeight(200)
+	messageScroll.SetPropagateNaturalHeight(true)
+	messageScroll.SetTooltipText("Commit message")
+	messageScroll.SetChild(p.message)
+
+	p.commit = gtk.NewButtonWithLabel("Commit")
+	p.commit.AddCSSClass("suggested-action")
+	p.commit.AddCSSClass("vcspanel-commit")
+	p.commit.SetTooltipText("Commit the staged changes (Ctrl+Enter)")
+	p.commit.SetSensitive(false)
+	p.commit.ConnectClicked(p.Commit)
+
+	p.staged = newChangeList("Staged Changes", "Unstage All", p.unstageAll)
+	p.staged.List.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		p.activate(p.staged.changes[row.Index()])
+	})
+
+	p.unstaged = newChangeList("Changes", "Stage All", p.stageAll)
+	p.unstaged.List.ConnectRowActivated(func(row *gtk.ListBoxRow) {
+		p.activate(p.unstaged.changes[row.Index()])
+	})
+
+	content := gtk.NewBox(gtk.OrientationVertical, 0)
+	content.Append(p.branch)
+	content.Append(messageScroll)
+	content.Append(p.commit)
+	content.Append(p.staged)
+	content.Append(p.unstaged)
+
+	p.content = gtk.NewScrolledWindow()
+	p.content.SetVExpand(true)
+	p.content.SetPolicy(gtk.PolicyNever, gtk.PolicyAutomatic)
+	p.content.SetChild(content)
+
+	p.empty = gtk.NewLabel("Not a git repository.")
+	p.empty.AddCSSClass("dim-label")
+	p.empty.SetWrap(true)
+	p.empty.SetJustify(gtk.JustifyCenter)
+
+	p.stack = gtk.NewStack()
+	p.stack.SetVExpand(true)
+	p.stack.SetTransitionType(gtk.StackTransitionTypeCrossfade)
+	p.stack.AddChild(p.empty)
+	p.stack.AddChild(p.content)
+	p.stack.SetVisibleChild(p.empty)
+
+	p.spinner = gtk.NewSpinner()
+	p.spinner.SetSizeRequest(32, 32)
+	p.spinner.AddCSSClass("vcspanel-loading")
+
+	p.spinbox = gtk.NewRevealer()
+	p.spinbox.SetTransitionDuration(65)
+	p.spinbox.SetTransitionType(gtk.RevealerTransitionTypeCrossfade)
+	p.spinbox.SetRevealChild(false)
+	p.spinbox.SetChild(p.spinner)
+	p.spinbox.NotifyProperty("reveal-child", func() {
+		if p.spinbox.RevealChild() {
+			p.spinner.Start()
+		} else {
+			p.spinner.Stop()
+		}
+	})
+
+	p.Actions = gtk.NewActionBar()
+	p.Actions.PackStart(p.spinbox)
+	p.Actions.PackEnd(newFnButton("send-to-symbolic", "Push", p.Push))
+	p.Actions.PackEnd(newFnButton("go-down-symbolic", "Pull", p.Pull))
+	p.Actions.PackEnd(newFnButton("emblem-synchronizing-symbolic", "Fetch", p.Fetch))
+	p.Actions.PackEnd(newFnButton("view-refresh-symbolic", "Refresh", p.Refresh))
+
+	p.Toast = toast.NewToast(gtk.PackEnd)
+	p.Toast.SetLog(true)
+	p.Toast.SetTimeout(5 * time.Second)
+	p.Toast.SetVAlign(gtk.AlignEnd)
+
+	overlay := gtk.NewOverlay()
+	overlay.SetChild(p.stack)
+	overlay.AddOverlay(p.Toast)
+
+	p.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	p.Box.Append(overlay)
+	p.Box.Append(p.Actions)
+	panelCSS(p.Box)
+
+	// Files change behind our back, so catch up whenever the page is shown.
+	p.ConnectMap(p.Refresh)
+
+	return &p
+}
+
+func newChangeList(title, allLabel string, all func()) changeList {
+	l := changeList{}
+	l.Label = gtk.NewLabel(title)
+	l.Label.SetXAlign(0)
+	l.Label.SetHExpand(true)
+	l.Label.AddCSSClass("heading")
+
+	allButton := gtk.NewButtonWithLabel(allLabel)
+	allButton.AddCSSClass("flat")
+	allButton.ConnectClicked(all)
+
+	header := gtk.NewBox(gtk.OrientationHorizontal, 0)
+	header.AddCSSClass("vcspanel-section")
+	header.Append(l.Label)
+	header.Append(allButton)
+
+	l.List = gtk.NewListBox()
+	l.List.SetSelectionMode(gtk.SelectionNone)
+	l.List.SetActivateOnSingleClick(true)
+
+	l.Box = gtk.NewBox(gtk.OrientationVertical, 0)
+	l.Box.Append(header)
+	l.Box.Append(l.List)
+	l.Box.Hide()
+
+	return l
+}
+
+func newFnButton(icon, tooltip string, f func()) *gtk.Button {
+	button := gtk.NewButtonFromIconName(icon)
+	button.SetTooltipText(tooltip)
+	button.ConnectClicked(f)
+	return button
+}
+
+// Load loads the repository containing the given folder. The panel shows a
+// placeholder if there's none.
+func (p *Panel) Load(root string) {
+	p.root = root
+	p.repo = nil
+	p.setChanges(nil)
+	p.stack.SetVisibleChild(p.empty)
+	p.Refresh()
+}
+
+// ConnectFileActivated connects f to be called when a changed file is clicked.
+// It does not call on deleted files.
+func (p *Panel) ConnectFileActivated(f func(path string)) {
+	p.activated = append(p.activated, f)
+}
+
+// ConnectChanged connects f to be called after the index, HEAD or the worktree
+// was changed through the panel.
+func (p *Panel) ConnectChanged(f func()) {
+	p.changed = append(p.changed, f)
+}
+
+// Refresh asynchronously reloads the list of changes.
+func (p *Panel) Refresh() {
+	root := p.root
+	if root == "" {
+		return
+	}
+
+	repo := p.repo
+	p.setBusy()
+
+	gtkutil.Async(p.ctx, func() func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		var err error
+		if repo == nil {
+			repo, err = vcs.Open(root)
+		}
+
+		var branch string
+		var changes []vcs.Change
+		if err == nil {
+			branch, err = repo.BranchName()
+		}
+		if err == nil {
+			changes, err = repo.Changes()
+		}
+
+		return func() {
+			p.setDone()
+
+			// Make sure we're still on the same folder.
+			if p.root != root {
+				return
+			}
+
+			if err != nil {
+				p.repo = nil
+				p.setChanges(nil)
+				p.stack.SetVisibleChild(p.empty)
+
+				if !errors.Is(err, git.ErrRepositoryNotExists) {
+					p.Toast.Show("Error: " + err.Error())
+				}
+				return
+			}
+
+			p.repo = repo
+			p.setBranch(branch)
+			p.setChanges(changes)
+			p.stack.SetVisibleChild(p.content)
+		}
+	})
+}
+
+// QueueRefresh refreshes the panel if it's shown. Otherwise, it's refreshed
+// once it's shown.
+func (p *Panel) QueueRefresh() {
+	if p.Mapped() {
+		p.Refresh()
+	}
+}
+
+func (p *Panel) setBranch(branch string) {
+	if branch == "" {
+		p.branch.SetText("No commits yet")
+		return
+	}
+
+	text := "On branch " + branch
+	if remote, err := p.repo.Remote(vcs.DefaultRemote); err == nil {
+		if urls := remote.Config().URLs; len(urls) > 0 {
+			p.branch.SetTooltipText(vcs.DefaultRemote + ": " + urls[0])
+		}
+	} else {
+		p.branch.SetTooltipText("No remote named " + vcs.DefaultRemote)
+	}
+	p.branch.SetText(text)
+}
+
+func (p *Panel) setChanges(changes []vcs.Change) {
+	var staged, unstaged []vcs.Change
+	for _, change := range changes {
+		if change.IsStaged() {
+			staged = append(staged, change)
+		}
+		if change.IsUnstaged() {
+			unstaged = append(unstaged, change)
+		}
+	}
+
+	p.staged.set(p, staged, true)
+	p.unstaged.set(p, unstaged, false)
+	p.updateCommit()
+}
+
+func (l *changeList) set(p *Panel, changes []vcs.Change, staged bool) {
+	for row := l.List.FirstChild(); row != nil; row = l.List.FirstChild() {
+		l.List.Remove(row)
+	}
+
+	l.changes = changes
+	for _, change := range changes {
+		l.List.Append(p.newRow(change, staged))
+	}
+
+	l.SetVisible(len(changes) > 0)
+}
+
+// newRow creates the row of a changed file, with a button to stage or unstage
+// it.
+func (p *Panel) newRow(change vcs.Change, staged bool) *gtk.ListBoxRow {
+	code := change.Worktree
+	if staged {
+		code = change.Staging
+	}
+
+	rel, err := filepath.Rel(p.root, change.Path)
+	if err != nil {
+		rel = change.Path
+	}
+
+	name := gtk.NewLabel(filepath.Base(change.Path))
+	name.SetXAlign(0)
+	name.SetHExpand(true)
+	name.SetEllipsize(pango.EllipsizeEnd)
+	name.SetTooltipText(rel)
+
+	marker := gtk.NewLabel("")
+	marker.SetMarkup(statusMarkup(code))
+	marker.SetTooltipText(statusName(code))
+
+	var button *gtk.Button
+	if staged {
+		button = newFnButton("list-remove-symbolic", "Unstage", func() {
+			p.run("Unstage", func(repo *vcs.Repository) error {
+				return repo.Unstage(change)
+			})
+		})
+	} else {
+		button = newFnButton("list-add-symbolic", "Stage", func() {
+			p.run("Stage", func(repo *vcs.Repository) error {
+				return repo.Stage(change)
+			})
+		})
+	}
+	button.AddCSSClass("flat")
+
+	box := gtk.NewBox(gtk.OrientationHorizontal, 6)
+	box.AddCSSClass("vcspanel-row")
+	box.Append(name)
+	box.Append(marker)
+	box.Append(button)
+
+	row := gtk.NewListBoxRow()
+	row.SetChild(box)
+	row.SetActivatable(code != git.Deleted)
+	return row
+}
+
+// statusMarkup returns the colored marker of the status code.
+func statusMarkup(code git.StatusCode) string {
+	var status vcs.FileStatus
+	switch code {
+	case git.Untracked:
+		status = vcs.Untracked
+	case git.Added, git.Copied:
+		status = vcs.Added
+	case git.UpdatedButUnmerged:
+		status = vcs.Conflicted
+	default:
+		status = vcs.Modified
+	}
+
+	marker := status.Marker()
+	switch code {
+	case git.Deleted:
+		marker = "D"
+	case git.Renamed:
+		marker = "R"
+	}
+
+	return `<span color="` + status.Color() + `"><b>` + marker + `</b></span>`
+}
+
+func statusName(code git.StatusCode) string {
+	switch code {
+	case git.Untracked:
+		return "Untracked"
+	case git.Added:
+		return "Added"
+	case git.Copied:
+		return "Copied"
+	case git.Deleted:
+		return "Deleted"
+	case git.Renamed:
+		return "Renamed"
+	case git.UpdatedButUnmerged:
+		return "Conflicted"
+	default:
+		return "Modified"
+	}
+}
+
+func (p *Panel) activate(change vcs.Change) {
+	if change.Worktree == git.Deleted {
+		return
+	}
+	for _, f := range p.activated {
+		f(change.Path)
+	}
+}
+
+func (p *Panel) updateCommit() {
+	p.commit.SetSensitive(len(p.staged.changes) > 0 && p.messageText() != "")
+}
+
+func (p *Panel) messageText() string {
+	buf := p.message.Buffer()
+	return strings.TrimSpace(buf.Text(buf.StartIter(), buf.EndIter(), false))
+}
+
+func (p *Panel) stageAll() {
+	changes := p.unstaged.changes
+	p.run("Stage", func(repo *vcs.Repository) error {
+		for _, change := range changes {
+			if err := repo.Stage(change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Panel) unstageAll() {
+	changes := p.staged.changes
+	p.run("Unstage", func(repo *vcs.Repository) error {
+		for _, change := range changes {
+			if err := repo.Unstage(change); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Commit commits the staged changes with the written message.
+func (p *Panel) Commit() {
+	message := p.messageText()
+	if message == "" {
+		return
+	}
+
+	p.run("Commit", func(repo *vcs.Repository) error {
+		return repo.Commit(message + "\n")
+	}, func() {
+		p.message.Buffer().SetText("")
+		p.Toast.Show("Committed.")
+	})
+}
+
+// Fetch fetches from the remote.
+func (p *Panel) Fetch() {
+	p.sync("Fetch", (*vcs.Repository).Fetch, "Fetched.")
+}
+
+// Pull pulls from the remote into the current branch.
+func (p *Panel) Pull() {
+	p.sync("Pull", (*vcs.Repository).Pull, "Pulled.")
+}
+
+// Push pushes the current branch to the remote.
+func (p *Panel) Push() {
+	p.sync("Push", (*vcs.Repository).Push, "Pushed.")
+}
+
+func (p *Panel) sync(name string, f func(*vcs.Repository, context.Context) error, done string) {
+	upToDate := false
+
+	p.run(name, func(repo *vcs.Repository) error {
+		err := f(repo, p.ctx)
+		if errors.Is(err, vcs.ErrUpToDate) {
+			upToDate = true
+			err = nil
+		}
+		return err
+	}, func() {
+		if upToDate {
+			p.Toast.Show("Already up to date.")
+		} else {
+			p.Toast.Show(done)
+		}
+	})
+}
+
+// run asynchronously runs the operation on the repository, then refreshes the
+// panel. Errors are shown in the toast. done is called on success.
+func (p *Panel) run(name string, op func(*vcs.Repository) error, done ...func()) {
+	repo := p.repo
+	if repo == nil {
+		return
+	}
+
+	p.setBusy()
+	p.stack.SetSensitive(false)
+
+	gtkutil.Async(p.ctx, func() func() {
+		p.mu.Lock()
+		err := op(repo)
+		p.mu.Unlock()
+
+		return func() {
+			p.setDone()
+			p.stack.SetSensitive(true)
+
+			if err != nil {
+				p.Toast.Show(name + " failed: " + err.Error())
+			} else {
+				for _, f := range done {
+					f()
+				}
+			}
+
+			p.Refresh()
+			for _, f := range p.changed {
+				f()
+			}
+		}
+	})
+}
+
+func (p *Panel) setBusy() {
+	p.spinbox.SetRevealChild(true)
+}
+
+func (p *Panel) setDone() {
+	p.spinbox.SetRevealChild(false)
+}
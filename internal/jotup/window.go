@@ -2,24 +2,29 @@ package jotup
 
 import (
 	"context"
-	"fmt"
 
-	"github.com/diamondburned/gotk4/pkg/gdk/v4"
 	"github.com/diamondburned/gotk4/pkg/gtk/v4"
 	"github.com/diamondburned/gotkit/app"
 	"github.com/diamondburned/gotkit/gtkutil"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
 )
 
-type key struct {
-	val  uint
-	mods gdk.ModifierType
+func init() {
+	keymap.Register(
+		keymap.Binding{Action: "win.open", Label: "Open Folder"},
+		keymap.Binding{Action: "win.open-copy", Label: "Open a Copy in a New Window"},
+		keymap.Binding{Action: "win.refresh", Label: "Refresh Folder"},
+		keymap.Binding{Action: "win.quick-open", Label: "Go to File", Accels: []string{"<Ctrl>P"}},
+		keymap.Binding{Action: "win.command-palette", Label: "Command Palette", Accels: []string{"<Ctrl><Shift>P"}},
+		keymap.Binding{Action: "win.switch-to-greeter", Label: "Back to Home"},
+	)
 }
 
 // Window is the main Jotup window.
 type Window struct {
 	*app.Window
-	ctx  context.Context
-	keys map[key]func() bool
+	ctx     context.Context
+	actions map[string]func() // bound to the window
 
 	Stack   *gtk.Stack
 	Greeter *GreeterPage
@@ -32,7 +37,7 @@ func NewWindowFromEditor(ctx context.Context, editor *EditorPage) *Window {
 	w := NewWindow(ctx)
 	w.SwitchToEditor()
 	w.Editor.loadFolder(editor.Files.Path())
-	w.Editor.selectFile(editor.Editor.Path())
+	w.Editor.selectFile(editor.Tabs.CurrentPath())
 	return w
 }
 
@@ -48,13 +53,8 @@ func NewWindow(ctx context.Context) *Window {
 	w := Window{
 		Window: win,
 		ctx:    ctx,
-		keys:   make(map[key]func() bool),
 	}
 
-	binder := gtk.NewEventControllerKey()
-	binder.ConnectKeyPressed(w.handleKeybinds)
-	w.AddController(binder)
-
 	w.Greeter = NewGreeter(ctx, w.Load)
 	w.Editor = NewEditorPage(ctx)
 
@@ -70,7 +70,9 @@ func NewWindow(ctx context.Context) *Window {
 	// Close will form an infinite recursion otherwise.
 	var wreckHavoc bool
 	w.ConnectCloseRequest(func() bool {
-		if !w.Editor.Editor.IsUnsaved() || wreckHavoc {
+		w.Editor.SaveSession()
+
+		if !w.Editor.IsUnsaved() || wreckHavoc {
 			return false // allow closing
 		}
 		w.Editor.AskBufferDestroy(func() {
@@ -82,16 +84,60 @@ func NewWindow(ctx context.Context) *Window {
 
 	win.SetChild(w.Stack)
 
-	gtkutil.BindActionMap(win, map[string]func(){
+	w.actions = map[string]func(){
 		"win.open":              w.Greeter.PromptOpenFolder,
 		"win.open-copy":         w.Editor.OpenCopy,
 		"win.refresh":           w.Editor.Files.Refresh,
+		"win.quick-open":        w.Editor.QuickOpen,
+		"win.command-palette":   w.CommandPalette,
 		"win.switch-to-greeter": w.SwitchToGreeter,
-	})
+	}
+	gtkutil.BindActionMap(win, w.actions)
+
+	keys := make(map[string]func() bool, len(w.actions))
+	for action, f := range w.actions {
+		f := f
+		keys[action] = func() bool {
+			f()
+			return true
+		}
+	}
+	for _, action := range app.FromContext(ctx).ListActions() {
+		action := "app." + action
+		keys[action] = func() bool { return w.ActivateAction(action, nil) }
+	}
+	// Only the editor has files to open and commands to run.
+	for _, action := range []string{"win.quick-open", "win.command-palette"} {
+		f := w.actions[action]
+		keys[action] = func() bool {
+			if !w.Editor.Mapped() {
+				return false
+			}
+			f()
+			return true
+		}
+	}
+	keymap.BindKeys(win, keys)
 
 	return &w
 }
 
+// RestoreLastSession loads the folder of the last session if restoring it is
+// enabled. It returns false if there's nothing to restore.
+func (w *Window) RestoreLastSession() bool {
+	if !reopenLastSession.Value() {
+		return false
+	}
+
+	root := lastSession(w.ctx)
+	if root == "" {
+		return false
+	}
+
+	w.Load(root)
+	return true
+}
+
 // SwitchToEditor switches the visible page to the Editor page.
 func (w *Window) SwitchToEditor() {
 	w.Stack.SetVisibleChild(w.Editor)
@@ -107,23 +153,3 @@ func (w *Window) Load(path string) {
 	w.SwitchToEditor()
 	w.Editor.Load(path)
 }
-
-// BindKey binds key to the given function. All keybinds added using this
-// function will be invoked before any other application keybinds.
-func (w *Window) BindKey(accel string, f func() bool) error {
-	val, mods, ok := gtk.AcceleratorParse(accel)
-	if !ok {
-		return fmt.Errorf("invalid acceleration %q", accel)
-	}
-
-	w.keys[key{val, mods}] = f
-	return nil
-}
-
-func (w *Window) handleKeybinds(keyval, _ uint, state gdk.ModifierType) (ok bool) {
-	f, ok := w.keys[key{keyval, state}]
-	if ok {
-		return f()
-	}
-	return false
-}
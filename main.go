@@ -13,8 +13,17 @@ import (
 	"github.com/diamondburned/gotkit/components/prefui"
 	"github.com/diamondburned/gotkit/gtkutil/cssutil"
 	"github.com/diamondburned/jotup/internal/jotup"
+	"github.com/diamondburned/jotup/internal/jotup/keymap"
 )
 
+func init() {
+	keymap.Register(
+		keymap.Binding{Action: "app.preferences", Label: "Preferences"},
+		keymap.Binding{Action: "app.logs", Label: "Show Logs"},
+		keymap.Binding{Action: "app.quit", Label: "Quit"},
+	)
+}
+
 var _ = cssutil.WriteCSS(`
 	windowhandle, headerbar {
 		min-height: 0;
@@ -42,6 +51,12 @@ var _ = cssutil.WriteCSS(`
 	.main-left > windowhandle > box {
 		margin: 0 4px;
 	}
+	.main-left-switcher {
+		margin: 0 4px 4px 4px;
+	}
+	.main-left-switcher button {
+		padding: 0 4px;
+	}
 	.main-right headerbar {
 		border-bottom: 1px solid @borders;
 	}
@@ -60,11 +75,17 @@ var _ = cssutil.WriteCSS(`
 	}
 `)
 
+const (
+	appID   = "com.diamondburned.jotup"
+	appName = "Jotup"
+)
+
 func main() {
-	app := app.NewWithFlags(
-		"com.diamondburned.jotup", "Jotup",
-		gio.ApplicationHandlesOpen,
-	)
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(os.Args[2:]))
+	}
+
+	app := app.NewWithFlags(appID, appName, gio.ApplicationHandlesOpen)
 
 	app.ConnectStartup(func() {
 		gtksource.Init()
@@ -81,8 +102,16 @@ func main() {
 	})
 
 	app.ConnectActivate(func() {
+		// Only the first window restores the last session. Launching jotup
+		// again opens the greeter, since two windows on the same folder would
+		// overwrite each other's session.
+		first := len(app.Windows()) == 0
+
 		w := jotup.NewWindow(app.Context())
 		w.Show()
+		if first {
+			w.RestoreLastSession()
+		}
 	})
 
 	app.ConnectOpen(func(files []gio.Filer, hint string) {